@@ -1,6 +1,6 @@
 module github.com/dsecuredcom/archive-finder
 
-go 1.19
+go 1.21
 
 require (
 	github.com/valyala/fasthttp v1.58.0
@@ -2,12 +2,12 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"io"
 	"log"
 	"math/rand"
-	"net/http"
 	"os"
-	"sync/atomic"
 	"time"
 
 	"github.com/dsecuredcom/archive-finder/src"
@@ -17,19 +17,61 @@ func main() {
 	log.SetOutput(io.Discard)
 	rand.Seed(time.Now().UnixNano())
 
+	if len(os.Args) > 1 && os.Args[1] == "db" {
+		if err := src.RunDBCommand(os.Args[2:], os.Stdout); err != nil {
+			src.PrintError("%v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		if err := src.RunDiffCommand(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			src.PrintError("%v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	src.PrintWithTime("Starting archive-finder...")
 
 	config := src.ParseFlags()
 
-	var stdClient *http.Client
-	var fastClient *src.FastHTTPClient
+	// -output records every finding, -only-new only filters what is printed
+	onlyNew := config.OnlyNew
+	show := func(finding src.Finding) {
+		if !onlyNew || !finding.Known {
+			printFinding(finding)
+		}
+	}
+
+	handler := show
+	if config.OutputFile != "" {
+		file, err := os.Create(config.OutputFile)
+		if err != nil {
+			src.PrintError("Error creating output file: %v", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+
+		encoder := json.NewEncoder(file)
+		handler = func(finding src.Finding) {
+			show(finding)
+			if err := encoder.Encode(finding); err != nil {
+				src.PrintError("Error writing output file: %v", err)
+			}
+		}
+	}
 
-	if config.UseFastHTTP {
-		// Falls fasthttp gewünscht:
-		fastClient = src.NewFastHTTPClient(config)
-	} else {
-		// Falls net/http gewünscht:
-		stdClient = src.NewHTTPClient(config)
+	scanner, err := src.NewScannerWithConfig(
+		config,
+		src.WithFindingHandler(handler),
+		src.WithOnlyNew(false),
+		src.WithLogHandler(printLog),
+	)
+	if err != nil {
+		src.PrintError("%v", err)
+		os.Exit(1)
 	}
 
 	stopProgress := make(chan struct{})
@@ -42,20 +84,59 @@ func main() {
 			case <-stopProgress:
 				return
 			case <-ticker.C:
-				done := atomic.LoadInt64(&config.CompletedRequests)
-				src.PrintProgressLine("Requests completed: %d", done)
+				if config.AdaptiveConcurrency {
+					src.PrintProgressLine("Requests completed: %d, concurrency: %d", scanner.CompletedRequests(), scanner.ConcurrencyLimit())
+				} else {
+					src.PrintProgressLine("Requests completed: %d", scanner.CompletedRequests())
+				}
 			}
 		}
 	}()
 
-	err := src.ProcessHostsFile(config, stdClient, fastClient)
+	err = scanner.ScanFile(context.Background(), config.HostsFile)
 	close(stopProgress)
 
-	done := atomic.LoadInt64(&config.CompletedRequests)
-	src.PrintWithTime("All done! Total requests: %d", done)
+	dead, abandoned := scanner.SkippedHosts()
+	unresolved := scanner.UnresolvedHosts()
+	grouped := scanner.GroupedHosts()
+	outOfScope := scanner.OutOfScopeHosts()
+	src.PrintWithTime("All done! Total requests: %d, skipped hosts: %d (out of scope: %d, unresolved: %d, dead: %d, abandoned: %d), grouped virtual hosts: %d",
+		scanner.CompletedRequests(), outOfScope+unresolved+dead+abandoned, outOfScope, unresolved, dead, abandoned, grouped)
 
 	if err != nil {
 		src.PrintError("Error processing hosts file: %v", err)
 		os.Exit(1)
 	}
 }
+
+func printFinding(finding src.Finding) {
+	switch finding.Type {
+	case src.FindingBackupFile:
+		src.PrintFoundBackupFile(finding.URL)
+	case src.FindingDirListing:
+		src.PrintFoundListing(finding.URL, finding.Detail)
+	default:
+		// Archives are always scored
+		confidence := *finding.Confidence
+		if finding.DuplicateOf != "" {
+			src.PrintFoundDuplicate(finding.URL, finding.DuplicateOf, confidence)
+			return
+		}
+		if finding.Mismatch {
+			src.PrintFoundMismatch(finding.URL, finding.FileType, confidence)
+			return
+		}
+		src.PrintFound(finding.URL, confidence)
+	}
+}
+
+func printLog(level src.LogLevel, msg string) {
+	switch level {
+	case src.LogError:
+		src.PrintError("%s", msg)
+	case src.LogVerbose:
+		src.PrintVerbose("%s", msg)
+	default:
+		src.PrintWithTime("%s", msg)
+	}
+}
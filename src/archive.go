@@ -1,11 +1,7 @@
 package src
 
 import (
-	"bytes"
 	"fmt"
-	"github.com/valyala/fasthttp"
-	"io"
-	"net/http"
 	"net/url"
 	"strings"
 	"sync/atomic"
@@ -18,6 +14,9 @@ var (
 		"tar",
 		"rar",
 		"tar.gz",
+		"sql.gz",
+		"tgz",
+		"jpa",
 		"7z",
 		"gz",
 		"bz2",
@@ -26,60 +25,11 @@ var (
 		"xls",
 		"xlsx",
 	}
-
-	magicBytes = map[string][]byte{
-		"zip":    {0x50, 0x4B, 0x03, 0x04},
-		"rar":    {0x52, 0x61, 0x72, 0x21},
-		"tar":    {0x75, 0x73, 0x74, 0x61, 0x72, 0x00},
-		"tar.gz": {0x1F, 0x8B},
-		"7z":     {0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C},
-		"gz":     {0x1F, 0x8B},
-		"bz2":    {0x42, 0x5A, 0x68},
-		"dll":    {0x4D, 0x5A},
-		"exe":    {0x4D, 0x5A},
-		"xls":    {0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1},
-		"xlsx":   {0x50, 0x4B, 0x03, 0x04},
-	}
 )
 
-func doHeadStd(archiveURL string, stdClient *http.Client) (int, string, error) {
-	req, err := http.NewRequest("HEAD", archiveURL, nil)
-	if err != nil {
-		return 0, "", err
-	}
-	req.Header.Set("User-Agent", GetRandomUserAgent())
-	resp, err := stdClient.Do(req)
-	if err != nil {
-		return 0, "", err
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode, resp.Header.Get("Content-Type"), nil
-}
-
-func doHeadFast(archiveURL string, fastClient *FastHTTPClient) (int, string, error) {
-	req := fasthttp.AcquireRequest()
-	defer fasthttp.ReleaseRequest(req)
-	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseResponse(resp)
-
-	req.SetRequestURI(archiveURL)
-	req.Header.SetMethod("HEAD")
-	req.Header.Set("User-Agent", GetRandomUserAgent())
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.SetProtocol("HTTP/1.1")
-
-	err := fastClient.client.Do(req, resp)
-	if err != nil {
-		return 0, "", err
-	}
-	return resp.StatusCode(), string(resp.Header.Peek("Content-Type")), nil
-}
-
-func GenerateArchivePaths(host string, config *Config) <-chan string {
+func GenerateArchivePaths(host string, config *Config, info *HostInfo) <-chan string {
 	archiveChan := make(chan string, 350) // Buffered channel for some throughput
 
-	basePaths, extensions, commonBackupFolders := GetBasePathsAndExtensions(config)
-
 	go func() {
 		defer close(archiveChan)
 		seen := make(map[string]struct{})
@@ -96,8 +46,13 @@ func GenerateArchivePaths(host string, config *Config) <-chan string {
 			return
 		}
 
-		if config.FetchHtmlFolders {
-			//folders := foldersFetched(host)
+		basePaths, extensions, commonBackupFolders, seedWords := hostWordlists(baseURL, config, info)
+
+		if info != nil && info.History != nil {
+			// Archives that existed before are re-checked first
+			for _, archiveURL := range info.History.ArchiveURLs {
+				addPath(archiveURL)
+			}
 		}
 
 		// Generate from basePaths + extensions
@@ -127,6 +82,16 @@ func GenerateArchivePaths(host string, config *Config) <-chan string {
 			}
 		}
 
+		// Words discovered in robots.txt, sitemap.xml, security.txt and historical URLs
+		for _, word := range seedWords {
+			for _, ext := range extensions {
+				addPath(fmt.Sprintf("%s%s.%s", baseURL, word, ext))
+				for _, backupFolder := range commonBackupFolders {
+					addPath(fmt.Sprintf("%s%s/%s.%s", baseURL, backupFolder, word, ext))
+				}
+			}
+		}
+
 		if config.ModuleFirstChars {
 			relevantString1 := firstSubdomainPart(baseURL, 3)
 			if relevantString1 != "" {
@@ -186,139 +151,172 @@ func GenerateArchivePaths(host string, config *Config) <-chan string {
 	return archiveChan
 }
 
-func doRequest(archiveURL string, config *Config, stdClient *http.Client, fastClient *FastHTTPClient) (int, string, []byte, error) {
-	const maxRead = 2048
-	var headStatus int
-	var headContentType string
-	var err error
-
-	if config.UseFastHTTP {
-		headStatus, headContentType, err = doHeadFast(archiveURL, fastClient)
-	} else {
-		headStatus, headContentType, err = doHeadStd(archiveURL, stdClient)
+// hostWordlists combines the configured wordlists with everything learned
+// about the host: detected profiles, harvested links, seed files and history.
+func hostWordlists(baseURL string, config *Config, info *HostInfo) (basePaths, extensions, folders, seedWords []string) {
+	basePaths, extensions, folders = GetBasePathsAndExtensions(config)
+	if info == nil {
+		return expandPlaceholders(basePaths, baseURL), extensions, folders, nil
 	}
-	if err != nil {
-		return 0, "", nil, err
+
+	basePaths, extensions, folders = applyProfiles(info.Profiles, basePaths, extensions, folders)
+	basePaths = expandPlaceholders(basePaths, baseURL)
+
+	if info.Links != nil {
+		folders = appendUnique(folders, info.Links.Folders...)
 	}
 
-	lc := strings.ToLower(headContentType)
-	if !(headStatus == 200 || headStatus == 206) || (!(strings.Contains(lc, "application")) && !(strings.Contains(lc, "octet"))) {
-		return headStatus, headContentType, nil, nil
+	if info.Seeds != nil {
+		folders = appendUnique(folders, info.Seeds.Folders...)
+		seedWords = info.Seeds.Words
 	}
 
-	if config.UseFastHTTP {
-		return fastClient.DoRequest(archiveURL, maxRead)
-	} else {
-		req, err := http.NewRequest("GET", archiveURL, nil)
-		if err != nil {
-			return 0, "", nil, err
-		}
-		req.Header.Set("User-Agent", GetRandomUserAgent())
+	if info.History != nil {
+		folders = appendUnique(folders, info.History.Folders...)
+		seedWords = appendUnique(seedWords, info.History.Names...)
+	}
 
-		resp, err := stdClient.Do(req)
-		if err != nil {
-			return 0, "", nil, err
-		}
-		defer resp.Body.Close()
+	return basePaths, extensions, folders, seedWords
+}
 
-		statusCode := resp.StatusCode
-		contentType := resp.Header.Get("Content-Type")
+func doRequest(archiveURL string, config *Config, prober Prober) (*ProbeResponse, error) {
+	const maxRead = 2048
 
-		// Die ersten maxRead Bytes lesen
-		buf := make([]byte, maxRead)
-		n, _ := io.ReadFull(resp.Body, buf) // Fehler ignorieren wir hier mal
-		buf = buf[:n]
+	head, err := prober.Head(config.context(), archiveURL)
+	if err != nil {
+		return nil, err
+	}
 
-		return statusCode, contentType, buf, nil
+	lc := strings.ToLower(head.Header.Get("Content-Type"))
+	if !isSuccess(head.StatusCode) || (!(strings.Contains(lc, "application")) && !(strings.Contains(lc, "octet"))) {
+		return head, nil
 	}
+
+	return prober.Get(config.context(), archiveURL, maxRead)
+}
+
+// CheckResult describes the outcome of a single candidate check.
+type CheckResult struct {
+	URL        string
+	StatusCode int
+	Found      bool
+	Detection  Detection
+	Confidence int
 }
 
 func CheckArchive(
 	archiveURL string,
-	stdClient *http.Client, // net/http client
-	fastClient *FastHTTPClient, // fasthttp client
+	prober Prober,
 	config *Config,
 	verbose bool,
-) {
+) CheckResult {
+	return checkArchive(archiveURL, prober, config, verbose, config.FindAll)
+}
+
+// checkArchive checks a candidate; with findAll it is requested and
+// reported even if the host already has a finding.
+func checkArchive(archiveURL string, prober Prober, config *Config, verbose bool, findAll bool) CheckResult {
+	result := CheckResult{URL: archiveURL}
+	defer atomic.AddInt64(&config.CompletedRequests, 1)
+
 	u, err := url.Parse(archiveURL)
 	if err != nil {
-		atomic.AddInt64(&config.CompletedRequests, 1)
-		return
+		return result
 	}
 	host := u.Host
+	origin := originKey(u)
 
 	config.FoundHostsMu.Lock()
-	alreadyFound := config.FoundHosts[host]
+	alreadyFound := config.FoundHosts[origin]
 	config.FoundHostsMu.Unlock()
 
-	if alreadyFound {
-		atomic.AddInt64(&config.CompletedRequests, 1)
-		return
+	if (alreadyFound && !findAll) || hostAbandoned(origin, config) {
+		return result
 	}
 
 	startTime := time.Now()
-	statusCode, ctype, body, err := doRequest(archiveURL, config, stdClient, fastClient)
+	resp, err := doRequest(archiveURL, config, prober)
 	duration := time.Since(startTime)
+	recordRequest(origin, err, config)
 
 	if err != nil {
 		if verbose {
-			PrintError("Request failed for %s: %v", archiveURL, err)
+			config.logf(LogError, "Request failed for %s: %v", archiveURL, err)
 		}
-		atomic.AddInt64(&config.CompletedRequests, 1)
-		return
+		return result
 	}
+	result.StatusCode = resp.StatusCode
 
 	if verbose {
-		sizeStr := "unknown" // fasthttp kann das nicht direkt, net/http liefert ContentLength
-		PrintVerbose("url=%s took=%v status=%d size=%s", archiveURL, duration, statusCode, sizeStr)
+		sizeStr := resp.Header.Get("Content-Length")
+		if sizeStr == "" {
+			sizeStr = "unknown"
+		}
+		config.logf(LogVerbose, "url=%s took=%v status=%d size=%s", archiveURL, duration, resp.StatusCode, sizeStr)
 	}
 
-	if statusCode == 200 {
-		if verifyBody(body, archiveURL, ctype) {
-			config.FoundHostsMu.Lock()
-			if !config.FoundHosts[host] {
-				config.FoundHosts[host] = true
-				config.FoundHostsMu.Unlock()
+	if !isSuccess(resp.StatusCode) {
+		return result
+	}
 
-				PrintFound(archiveURL) // Nur einmal pro Host
-			} else {
-				config.FoundHostsMu.Unlock()
-			}
+	detection, ok := detect(resp.Header, resp.Body, getExtension(archiveURL))
+	if !ok {
+		return result
+	}
+
+	confidence := scoreFinding(archiveURL, resp, detection, config, prober)
+	if confidence < config.MinConfidence {
+		if verbose {
+			config.logf(LogVerbose, "url=%s type=%s confidence=%d below threshold", archiveURL, detection.Type, confidence)
 		}
+		return result
 	}
 
-	atomic.AddInt64(&config.CompletedRequests, 1)
-}
+	result.Found = true
+	result.Detection = detection
+	result.Confidence = confidence
 
-func verifyBody(body []byte, archiveURL string, ctype string) bool {
-	if strings.Contains(strings.ToLower(ctype), "text/html") {
-		return false
+	payload := payloadKey(resp)
+	firstURL, duplicate := config.clusters.record(payload, payloadSize(resp), archiveURL, host)
+	if duplicate && config.SuppressDuplicates {
+		if verbose {
+			config.logf(LogVerbose, "url=%s duplicate payload of %s suppressed", archiveURL, firstURL)
+		}
+		return result
 	}
 
-	ext := getExtension(archiveURL)
-	n := len(body)
-	if n == 0 {
-		return false
+	finding := Finding{
+		Type:         FindingArchive,
+		URL:          archiveURL,
+		Host:         host,
+		FileType:     detection.Type,
+		Mismatch:     detection.Mismatch,
+		Confidence:   &confidence,
+		PayloadHash:  shortHash(payload),
+		Size:         payloadSize(resp),
+		LastModified: resp.Header.Get("Last-Modified"),
 	}
-
-	lowerChunk := strings.ToLower(string(body))
-	if strings.Contains(lowerChunk, "<html") || strings.Contains(lowerChunk, "<!doctype") {
-		return false
+	if duplicate {
+		finding.DuplicateOf = firstURL
 	}
 
-	if ext == "tar" {
+	config.FoundHostsMu.Lock()
+	if !config.FoundHosts[origin] || findAll {
+		config.FoundHosts[origin] = true
+		config.FoundHostsMu.Unlock()
 
-		if n < 512 {
-			return false
-		}
-		return bytes.Equal(body[257:262], []byte("ustar")) ||
-			bytes.Equal(body[257:263], []byte("ustar\000"))
+		config.report(finding) // Nur einmal pro Host, außer bei -find-all
+	} else {
+		config.FoundHostsMu.Unlock()
 	}
 
-	if magic, ok := magicBytes[ext]; ok {
-		return bytes.HasPrefix(body, magic)
-	}
-	return false
+	return result
+}
+
+// originKey identifies the scheme and host of u, so http and https of a
+// host are found and abandoned separately.
+func originKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
 }
 
 func getExtension(archiveURL string) string {
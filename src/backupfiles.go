@@ -0,0 +1,224 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	knownBackupFiles = []string{
+		"index.php",
+		"config.php",
+		"wp-config.php",
+		"configuration.php",
+		"settings.php",
+		"config.inc.php",
+		"database.php",
+		"db.php",
+		"web.config",
+		".env",
+		"database.yml",
+		"config.yml",
+		"settings.py",
+		"local_settings.py",
+		"application.properties",
+		"appsettings.json",
+		".htaccess",
+	}
+
+	// backupSuffixes are format strings that receive the original file name.
+	backupSuffixes = []string{
+		"%s.bak",
+		"%s~",
+		".%s.swp",
+		"%s.old",
+		"%s.orig",
+		"%s.save",
+		"%s.backup",
+		"%s.bk",
+		"%s.tmp",
+		"%s.copy",
+		"%s_bak",
+		"%s.1",
+	}
+
+	// backupSignatures are lowercase content markers of the original file
+	// type, keyed by the extension of the original file name.
+	backupSignatures = map[string][]string{
+		"php":        {"<?php", "<?="},
+		"config":     {"<configuration", "<system.web", "connectionstring"},
+		"env":        {"app_key=", "db_password=", "db_host=", "database_url=", "secret_key="},
+		"yml":        {"adapter:", "database:", "password:", "username:"},
+		"py":         {"secret_key", "databases", "allowed_hosts", "installed_apps", "root_urlconf"},
+		"properties": {"spring.", "jdbc:", "datasource."},
+		"json":       {"\"connectionstrings\"", "\"logging\"", "\"password\""},
+		"htaccess":   {"rewriteengine", "authtype", "deny from", "require ", "options "},
+	}
+
+	// backupSignatureMinimum is the number of distinct markers required for
+	// types whose single markers also occur in unrelated files. Django
+	// settings always define several of theirs.
+	backupSignatureMinimum = map[string]int{"py": 2}
+
+	vimSwapMagic = []byte("b0VIM")
+	htmlPageRe   = regexp.MustCompile(`<html|<!doctype|<body`)
+)
+
+// GenerateBackupFilePaths emits editor and backup-suffix variants of the
+// built-in known files in the root and, if available, of the harvested
+// files in their own folder.
+func GenerateBackupFilePaths(host string, config *Config, info *HostInfo) <-chan string {
+	backupChan := make(chan string, 350)
+
+	go func() {
+		defer close(backupChan)
+		seen := make(map[string]struct{})
+
+		baseURL := normalizeHost(host)
+		if baseURL == "" {
+			return
+		}
+
+		files := append([]string{}, knownBackupFiles...)
+		if info != nil && info.Links != nil {
+			for _, file := range info.Links.Files {
+				if _, ok := backupSignatures[backupFileType(file)]; ok {
+					files = append(files, file)
+				}
+			}
+		}
+
+		for _, file := range files {
+			dir, name := path.Split(file)
+			for _, suffix := range backupSuffixes {
+				candidate := baseURL + dir + fmt.Sprintf(suffix, name)
+				if _, ok := seen[candidate]; !ok {
+					seen[candidate] = struct{}{}
+					backupChan <- candidate
+				}
+			}
+		}
+	}()
+
+	return backupChan
+}
+
+// CheckBackupFile requests a backup-suffix candidate and verifies it by the
+// content signature of the original file type instead of archive magic bytes.
+func CheckBackupFile(
+	backupURL string,
+	prober Prober,
+	config *Config,
+	verbose bool,
+) {
+	defer atomic.AddInt64(&config.CompletedRequests, 1)
+
+	u, err := url.Parse(backupURL)
+	if err != nil {
+		return
+	}
+	host := u.Host
+	origin := originKey(u)
+
+	config.FoundHostsMu.Lock()
+	alreadyFound := config.FoundBackupHosts[origin]
+	config.FoundHostsMu.Unlock()
+
+	if (alreadyFound && !config.FindAll) || hostAbandoned(origin, config) {
+		return
+	}
+
+	resp, err := prober.Get(config.context(), backupURL, 2048)
+	recordRequest(origin, err, config)
+	if err != nil {
+		if verbose {
+			config.logf(LogError, "Request failed for %s: %v", backupURL, err)
+		}
+		return
+	}
+
+	if verbose {
+		config.logf(LogVerbose, "url=%s status=%d type=backup", backupURL, resp.StatusCode)
+	}
+
+	if !isSuccess(resp.StatusCode) || !verifyBackupBody(resp.Body, u.Path, resp.Header.Get("Content-Type")) {
+		return
+	}
+
+	config.FoundHostsMu.Lock()
+	if config.FoundBackupHosts[origin] && !config.FindAll {
+		config.FoundHostsMu.Unlock()
+		return
+	}
+	config.FoundBackupHosts[origin] = true
+	config.FoundHostsMu.Unlock()
+
+	config.report(Finding{Type: FindingBackupFile, URL: backupURL, Host: host})
+}
+
+func verifyBackupBody(body []byte, urlPath string, ctype string) bool {
+	if len(body) == 0 {
+		return false
+	}
+
+	// Vim swap files carry their own magic, regardless of the original type
+	if strings.HasSuffix(urlPath, ".swp") && bytes.HasPrefix(body, vimSwapMagic) {
+		return true
+	}
+
+	lowerChunk := strings.ToLower(string(body))
+	fileType := backupFileType(originalFileName(path.Base(urlPath)))
+	signatures, ok := backupSignatures[fileType]
+	if !ok {
+		return false
+	}
+
+	// A rendered page is never the source of the original file
+	if strings.Contains(strings.ToLower(ctype), "text/html") && htmlPageRe.MatchString(lowerChunk) &&
+		!strings.Contains(lowerChunk, "<?php") {
+		return false
+	}
+
+	need := backupSignatureMinimum[fileType]
+	if need == 0 {
+		need = 1
+	}
+	for _, signature := range signatures {
+		if strings.Contains(lowerChunk, signature) {
+			need--
+			if need == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// originalFileName strips the backup suffix again, e.g. ".config.php.swp"
+// becomes "config.php".
+func originalFileName(name string) string {
+	for _, suffix := range backupSuffixes {
+		prefix, rest, ok := strings.Cut(suffix, "%s")
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, rest) && len(name) > len(prefix)+len(rest) {
+			return name[len(prefix) : len(name)-len(rest)]
+		}
+	}
+	return name
+}
+
+func backupFileType(name string) string {
+	// path.Ext also covers dotfiles such as ".env" and ".htaccess"
+	ext := path.Ext(name)
+	if ext == "" {
+		return ""
+	}
+	return strings.ToLower(ext[1:])
+}
@@ -0,0 +1,62 @@
+package src
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestBackupPathsOfHarvestedFiles(t *testing.T) {
+	info := &HostInfo{Links: &HarvestedLinks{Files: []string{"includes/connect.php", "app.js"}}}
+	candidates := make(map[string]bool)
+	for candidate := range GenerateBackupFilePaths("example.com", DefaultConfig(), info) {
+		candidates[candidate] = true
+	}
+
+	for _, want := range []string{
+		"https://example.com/includes/connect.php.bak",
+		"https://example.com/includes/.connect.php.swp",
+		"https://example.com/config.php~",
+	} {
+		if !candidates[want] {
+			t.Errorf("missing candidate %s", want)
+		}
+	}
+	for _, unwanted := range []string{"https://example.com/connect.php.bak", "https://example.com/app.js.bak"} {
+		if candidates[unwanted] {
+			t.Errorf("unexpected candidate %s", unwanted)
+		}
+	}
+}
+
+func TestVerifyBackupBody(t *testing.T) {
+	tests := []struct {
+		path string
+		body string
+		want bool
+	}{
+		{"/config.php.bak", "<?php\n$db = 'x';", true},
+		{"/settings.py.bak", "import os\nSECRET_KEY = 'x'\nALLOWED_HOSTS = []", true},
+		{"/settings.py.bak", "import os\nimport sys\n", false},
+		{"/settings.py.bak", "# the secret_key lives elsewhere", false},
+		{"/.env.old", "APP_KEY=base64:x", true},
+		{"/.env.old", "hello", false},
+	}
+	for _, tt := range tests {
+		if got := verifyBackupBody([]byte(tt.body), tt.path, "text/plain"); got != tt.want {
+			t.Errorf("verifyBackupBody(%q, %q) = %v, want %v", tt.path, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestHarvestedFilesKeepPath(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/shop/")
+	body := []byte(`<a href="/includes/connect.php">x</a><script src="app.js"></script><a href="https://other.com/x.php">y</a>`)
+	links := &HarvestedLinks{}
+	extractLinks(body, pageURL, links)
+
+	want := []string{"includes/connect.php", "shop/app.js"}
+	if !reflect.DeepEqual(links.Files, want) {
+		t.Errorf("got files %v, want %v", links.Files, want)
+	}
+}
@@ -0,0 +1,96 @@
+package src
+
+import (
+	"sort"
+	"time"
+)
+
+// hostBudget limits the candidate requests of a single host by number and
+// by time. A nil budget is unlimited.
+type hostBudget struct {
+	host        string
+	config      *Config
+	maxRequests int
+	deadline    time.Time
+	requests    int
+	exhausted   bool
+}
+
+// newHostBudget returns the budget of host, or nil if no limit is set.
+func newHostBudget(host string, config *Config) *hostBudget {
+	if config.MaxRequestsPerHost <= 0 && config.MaxTimePerHost <= 0 {
+		return nil
+	}
+	budget := &hostBudget{host: host, config: config, maxRequests: config.MaxRequestsPerHost}
+	if config.MaxTimePerHost > 0 {
+		budget.deadline = time.Now().Add(config.MaxTimePerHost)
+	}
+	return budget
+}
+
+// take uses up one request. It returns false once the budget is spent. The
+// budget is only ever used by the goroutine scanning the host.
+func (b *hostBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	if !b.exhausted {
+		switch {
+		case b.maxRequests > 0 && b.requests >= b.maxRequests:
+			b.exhausted = true
+		case !b.deadline.IsZero() && time.Now().After(b.deadline):
+			b.exhausted = true
+		}
+		if b.exhausted && b.config.Verbose {
+			b.config.logf(LogVerbose, "host=%s budget exhausted after %d requests", b.host, b.requests)
+		}
+	}
+	if b.exhausted {
+		return false
+	}
+	b.requests++
+	return true
+}
+
+// prioritizeCandidates orders the candidates of a host by their likelihood
+// to be an archive, so the most promising are requested first, before a
+// budget cuts the rest or the first finding ends the host. Archives known
+// from history always come first. Sorting needs all candidates of the host
+// in memory, so without a budget or a stats file they are streamed in the
+// order of the generator.
+func prioritizeCandidates(ch <-chan string, config *Config, info *HostInfo) <-chan string {
+	if config.MaxRequestsPerHost <= 0 && config.MaxTimePerHost <= 0 && config.StatsFile == "" {
+		return ch
+	}
+
+	known := make(map[string]bool)
+	if info != nil && info.History != nil {
+		for _, archiveURL := range info.History.ArchiveURLs {
+			known[archiveURL] = true
+		}
+	}
+
+	type scored struct {
+		url   string
+		score float64
+	}
+	var candidates []scored
+	for candidate := range ch {
+		score := 1.0
+		if !known[candidate] {
+			score = config.model.likelihood(candidate)
+		}
+		candidates = append(candidates, scored{url: candidate, score: score})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	out := make(chan string, len(candidates))
+	for _, candidate := range candidates {
+		out <- candidate.url
+	}
+	close(out)
+	return out
+}
@@ -0,0 +1,80 @@
+package src
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHostBudgetTake(t *testing.T) {
+	var unlimited *hostBudget
+	for i := 0; i < 1000; i++ {
+		if !unlimited.take() {
+			t.Fatal("nil budget ran out")
+		}
+	}
+
+	config := DefaultConfig()
+	if newHostBudget("example.com", config) != nil {
+		t.Error("budget without limits is not nil")
+	}
+
+	config.MaxRequestsPerHost = 3
+	budget := newHostBudget("example.com", config)
+	var taken int
+	for i := 0; i < 5; i++ {
+		if budget.take() {
+			taken++
+		}
+	}
+	if taken != 3 {
+		t.Errorf("took %d requests, want 3", taken)
+	}
+
+	config.MaxRequestsPerHost = 0
+	config.MaxTimePerHost = time.Millisecond
+	budget = newHostBudget("example.com", config)
+	if !budget.take() {
+		t.Error("time budget spent before its deadline")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if budget.take() {
+		t.Error("time budget still open after its deadline")
+	}
+}
+
+func TestPrioritizeCandidates(t *testing.T) {
+	candidates := []string{
+		"https://example.com/foo.exe",
+		"https://example.com/backup.zip",
+		"https://example.com/old/known.rar",
+		"https://example.com/www.tar.gz",
+	}
+	config := DefaultConfig()
+	config.model = &candidateModel{}
+
+	// Without a budget or stats file the order of the generator is kept
+	var got []string
+	for candidate := range prioritizeCandidates(sliceChan(candidates), config, nil) {
+		got = append(got, candidate)
+	}
+	if !reflect.DeepEqual(got, candidates) {
+		t.Errorf("unbudgeted: got %v, want %v", got, candidates)
+	}
+
+	config.MaxRequestsPerHost = 10
+	info := &HostInfo{History: &HistoryPaths{ArchiveURLs: []string{"https://example.com/old/known.rar"}}}
+	got = nil
+	for candidate := range prioritizeCandidates(sliceChan(candidates), config, info) {
+		got = append(got, candidate)
+	}
+	want := []string{
+		"https://example.com/old/known.rar",
+		"https://example.com/backup.zip",
+		"https://example.com/www.tar.gz",
+		"https://example.com/foo.exe",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("budgeted: got %v, want %v", got, want)
+	}
+}
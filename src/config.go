@@ -1,6 +1,7 @@
 package src
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -13,7 +14,8 @@ type Config struct {
 	HostsFile             string
 	Timeout               time.Duration
 	Concurrency           int
-	ChunkSize             int
+	ParallelHosts         int
+	AdaptiveConcurrency   bool
 	DisableDynamicEntries bool
 	Verbose               bool
 	CompletedRequests     int64
@@ -30,23 +32,127 @@ type Config struct {
 	ModuleFirstChars      bool
 	BackupFolders         []string
 	FetchHtmlFolders      bool
+	ModuleBackupFiles     bool
+	FoundBackupHosts      map[string]bool
+	Profiles              []*Profile
+	AutoProfile           bool
+	ModuleSeedFiles       bool
+	SeedLimit             int
+	HistoryFile           string
+	History               map[string]*HistoryPaths
+	FindAll               bool
+	Recursive             bool
+	RecursionDepth        int
+	RecursionBudget       int
+	ModuleDirListing      bool
+	MinConfidence         int
+	SuppressDuplicates    bool
+	SchemeMode            string
+	SkipDeadHosts         bool
+	MaxHostErrors         int
+	Retries               int
+	RetryBackoff          time.Duration
+	DeadHosts             int64
+	AbandonedHosts        int64
+	ResolveHosts          bool
+	Resolvers             []string
+	DNSConcurrency        int
+	GroupByIP             bool
+	UnresolvedHosts       int64
+	VHostGroups           bool
+	PerIPConcurrency      int
+	GroupedHosts          int64
+	StatsFile             string
+	OutputFile            string
+	DBFile                string
+	OnlyNew               bool
+	MaxRequestsPerHost    int
+	MaxTimePerHost        time.Duration
+	ScopeFile             string
+	ExcludeFile           string
+	Scope                 *Scope
+	OutOfScopeHosts       int64
+	OnFinding             func(Finding)
+	OnLog                 func(LogLevel, string)
+
+	ctx       context.Context
+	sink      func(Finding)
+	reportMu  sync.Mutex
+	scores    scoreState
+	clusters  payloadClusters
+	breaker   hostBreaker
+	limiter   *limiter
+	adaptive  *adaptiveController
+	pool      *requestPool
+	ipLimits  ipLimiters
+	vhosts    map[string]*vhostGroup
+	dns       dnsCache
+	model     *candidateModel
+	db        *ResultsDB
+	scan      *ScanRecord
+	optionErr error
+}
+
+// DefaultConfig returns the settings used when no flags or options are given.
+func DefaultConfig() *Config {
+	return &Config{
+		Timeout:         60 * time.Second,
+		Concurrency:     2500,
+		ParallelHosts:   500,
+		Intensity:       "medium",
+		SeedLimit:       20,
+		RecursionDepth:  2,
+		RecursionBudget: 500,
+		SchemeMode:      SchemePreferHTTPS,
+		SkipDeadHosts:   true,
+		MaxHostErrors:   10,
+		Retries:         2,
+		RetryBackoff:    500 * time.Millisecond,
+		DNSConcurrency:  100,
+	}
 }
 
 func ParseFlags() *Config {
 	var wordList string
 	var extensionList string
 	var backupFolders string
+	var profileList string
+	var resolverList string
 
-	config := &Config{}
+	config := DefaultConfig()
 	flag.StringVar(&config.HostsFile, "hosts", "", "Path to hosts list file")
-	flag.DurationVar(&config.Timeout, "timeout", 60*time.Second, "Timeout for HTTP requests")
-	flag.IntVar(&config.Concurrency, "concurrency", 2500, "Maximum number of concurrent requests")
-	flag.IntVar(&config.ChunkSize, "chunksize", 500, "Chunksize for internal processing")
+	flag.DurationVar(&config.Timeout, "timeout", config.Timeout, "Timeout for HTTP requests")
+	flag.IntVar(&config.Concurrency, "concurrency", config.Concurrency, "Maximum number of concurrent requests")
+	flag.BoolVar(&config.AdaptiveConcurrency, "adaptive-concurrency", false, "Adjust the concurrency to latency, errors and timeouts, with -concurrency as upper bound")
+	flag.IntVar(&config.ParallelHosts, "parallel-hosts", config.ParallelHosts, "Number of hosts scanned at the same time")
+	flag.IntVar(&config.ParallelHosts, "chunksize", config.ParallelHosts, "Deprecated alias of -parallel-hosts")
 	flag.BoolVar(&config.Verbose, "verbose", false, "Enable verbose output")
-	flag.StringVar(&config.Intensity, "intensity", "medium", "Choose scanning intensity: small, medium, or big")
+	flag.StringVar(&config.Intensity, "intensity", config.Intensity, "Choose scanning intensity: small, medium, or big")
 	flag.StringVar(&wordList, "words", "", "Comma-separated list of words (overwrites intensity-based words)")
 	flag.StringVar(&extensionList, "extensions", "", "Comma-separated list of extensions (overwrites intensity-based extensions)")
 	flag.StringVar(&backupFolders, "backup-folders", "", "Comma-separated list of backup folders (overwrites intensity-based folders)")
+	flag.StringVar(&config.StatsFile, "stats-file", "", "Path to a file with hit rates from past scans to order candidates by; updated after the scan")
+	flag.StringVar(&config.OutputFile, "output", "", "Path to a file to write all findings to as JSON lines")
+	flag.StringVar(&config.DBFile, "db", "", "Path to the results database file recording every scan, host and finding")
+	flag.BoolVar(&config.OnlyNew, "only-new", false, "Only report findings not recorded in the results database by an earlier scan")
+	flag.IntVar(&config.MaxRequestsPerHost, "max-requests-per-host", 0, "Maximum number of candidate requests per host, most promising first (0 means no limit)")
+	flag.DurationVar(&config.MaxTimePerHost, "max-time-per-host", 0, "Stop requesting new candidates of a host after this duration (0 means no limit)")
+	flag.StringVar(&config.ScopeFile, "scope", "", "Path to a file with the hosts in scope: domains, *.wildcards, IPs, CIDRs or re:regexes")
+	flag.StringVar(&config.ExcludeFile, "exclude", "", "Path to a file with hosts to exclude, same format as -scope")
+	flag.BoolVar(&config.FindAll, "find-all", false, "Report every archive per host instead of stopping after the first")
+	flag.IntVar(&config.MinConfidence, "min-confidence", 0, "Only report archives with a confidence score (0-100) of at least this value")
+	flag.BoolVar(&config.SuppressDuplicates, "suppress-duplicates", false, "Report a payload served by several URLs only for the first one")
+	flag.StringVar(&config.SchemeMode, "scheme", config.SchemeMode, schemeModeUsage())
+	flag.BoolVar(&config.SkipDeadHosts, "skip-dead-hosts", config.SkipDeadHosts, "Connect to every host before scanning and skip hosts that do not answer")
+	flag.IntVar(&config.MaxHostErrors, "max-host-errors", config.MaxHostErrors, "Abandon a host after this many consecutive connection errors or timeouts (0 disables)")
+	flag.IntVar(&config.Retries, "retries", config.Retries, "Retries per request for timeouts, connection resets and 429/502/503/504 answers")
+	flag.DurationVar(&config.RetryBackoff, "retry-backoff", config.RetryBackoff, "Initial delay between retries, doubled per attempt")
+	flag.BoolVar(&config.ResolveHosts, "resolve", false, "Resolve all hosts before scanning and drop unresolvable ones")
+	flag.StringVar(&resolverList, "resolvers", "", "Comma-separated list of DNS servers for -resolve (default system resolver)")
+	flag.IntVar(&config.DNSConcurrency, "dns-concurrency", config.DNSConcurrency, "Maximum number of concurrent DNS lookups")
+	flag.BoolVar(&config.GroupByIP, "group-by-ip", false, "Spread hosts sharing an IP address over the scan (implies -resolve)")
+	flag.BoolVar(&config.VHostGroups, "vhost-groups", false, "Scan only one representative of hosts sharing an IP address and serving the same content (implies -resolve)")
+	flag.IntVar(&config.PerIPConcurrency, "per-ip-concurrency", 0, "Maximum number of concurrent requests per IP address (0 means no limit)")
 	flag.BoolVar(&config.UseFastHTTP, "fasthttp", false, "Use fasthttp instead of net/http")
 	flag.BoolVar(&config.OnlyDynamicEntries, "only-dynamic-entries", false, "Use only dynamically generated entries")
 	flag.BoolVar(&config.ModuleYears, "with-year", false, "Generate based on current year")
@@ -54,6 +160,15 @@ func ParseFlags() *Config {
 	flag.BoolVar(&config.ModuleDate, "with-date", false, "Generate based on current date")
 	flag.BoolVar(&config.ModuleDomainParts, "with-host-parts", false, "Generate based on host parts")
 	flag.BoolVar(&config.FetchHtmlFolders, "with-fetch-html", false, "Extract folders from HTML content")
+	flag.StringVar(&profileList, "profile", "", "Comma-separated list of CMS profiles ("+strings.Join(ProfileNames(), ", ")+") or auto for fingerprint detection")
+	flag.BoolVar(&config.ModuleSeedFiles, "with-seed-files", false, "Seed folders and words from robots.txt, sitemap.xml and security.txt")
+	flag.IntVar(&config.SeedLimit, "seed-limit", config.SeedLimit, "Maximum number of folders and words taken from seed files per host")
+	flag.StringVar(&config.HistoryFile, "history-file", "", "Path to a file with historical URLs (e.g. Wayback/Common Crawl exports) to mine archive names from")
+	flag.BoolVar(&config.ModuleDirListing, "with-dir-listing", false, "Request backup folders with a trailing slash and parse directory listings")
+	flag.BoolVar(&config.Recursive, "recursive", false, "Generate follow-up candidates from found archives and existing folders")
+	flag.IntVar(&config.RecursionDepth, "recursion-depth", config.RecursionDepth, "Maximum number of follow-up rounds per host")
+	flag.IntVar(&config.RecursionBudget, "recursion-budget", config.RecursionBudget, "Maximum number of follow-up requests per host")
+	flag.BoolVar(&config.ModuleBackupFiles, "with-backup-files", false, "Probe editor and backup-suffix variants of known files")
 
 	flag.Parse()
 
@@ -63,6 +178,16 @@ func ParseFlags() *Config {
 		os.Exit(1)
 	}
 
+	if !validSchemeMode(config.SchemeMode) {
+		fmt.Fprintln(os.Stderr, schemeModeUsage())
+		os.Exit(1)
+	}
+
+	if config.OnlyNew && config.DBFile == "" {
+		fmt.Fprintln(os.Stderr, "-only-new requires -db.")
+		os.Exit(1)
+	}
+
 	if wordList != "" {
 		config.UserBaseWords = strings.Split(wordList, ",")
 	}
@@ -71,11 +196,23 @@ func ParseFlags() *Config {
 		config.UserExtensions = strings.Split(extensionList, ",")
 	}
 
+	if resolverList != "" {
+		config.Resolvers = strings.Split(resolverList, ",")
+	}
+
 	if backupFolders != "" {
 		config.BackupFolders = strings.Split(backupFolders, ",")
 	}
 
-	config.FoundHosts = make(map[string]bool)
+	if profileList != "" {
+		profiles, auto, err := parseProfiles(profileList)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		config.Profiles = profiles
+		config.AutoProfile = auto
+	}
 
 	return config
 }
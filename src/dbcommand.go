@@ -0,0 +1,101 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const dbUsage = `Usage: archive-finder db <scans|hosts|findings> -db <file> [options]
+
+  scans     List all scans with their number of hosts and findings
+  hosts     List the hosts covered by a scan (-scan) or by all scans
+  findings  List findings, filtered by -scan, -host, -type, -since and -new
+`
+
+// RunDBCommand runs the db subcommand with the arguments following "db"
+// and writes its output to out.
+func RunDBCommand(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" {
+		fmt.Fprint(out, dbUsage)
+		return nil
+	}
+	command := args[0]
+
+	flags := flag.NewFlagSet("db "+command, flag.ContinueOnError)
+	flags.SetOutput(out)
+	dbFile := flags.String("db", "", "Path to the results database file")
+	scanID := flags.String("scan", "", "Only this scan, \"last\" for the latest")
+	host := flags.String("host", "", "Only findings of this host")
+	findingType := flags.String("type", "", "Only findings of this type: archive, backup-file or directory-listing")
+	since := flags.Duration("since", 0, "Only findings of the last duration, e.g. 168h")
+	onlyNew := flags.Bool("new", false, "Only findings that were new in their scan")
+	asJSON := flags.Bool("json", false, "Print JSON lines instead of text")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *dbFile == "" {
+		return errors.New("-db is required")
+	}
+
+	// Querying must not create an empty database by accident
+	if _, err := os.Stat(*dbFile); err != nil {
+		return err
+	}
+	db, err := OpenResultsDB(*dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *scanID == "last" {
+		*scanID = ""
+		if scans := db.Scans(); len(scans) > 0 {
+			*scanID = scans[len(scans)-1].ID
+		}
+	}
+
+	emit := func(v interface{}, text string) {
+		if *asJSON {
+			data, _ := json.Marshal(v)
+			fmt.Fprintln(out, string(data))
+			return
+		}
+		fmt.Fprintln(out, text)
+	}
+
+	switch command {
+	case "scans":
+		for _, scan := range db.Scans() {
+			emit(scan, fmt.Sprintf("%s  started %s  hosts %d  findings %d  new %d",
+				scan.ID, scan.Started.Local().Format(time.RFC3339), scan.Hosts, scan.Findings, scan.New))
+		}
+	case "hosts":
+		for _, record := range db.Hosts(*scanID) {
+			emit(record, fmt.Sprintf("%s  %s  %s", record.Time.Local().Format(time.RFC3339), record.Scan, record.Host))
+		}
+	case "findings":
+		filter := FindingFilter{Scan: *scanID, Host: *host, Type: FindingType(*findingType), OnlyNew: *onlyNew}
+		if *since > 0 {
+			filter.Since = time.Now().Add(-*since)
+		}
+		for _, record := range db.Findings(filter) {
+			text := fmt.Sprintf("%s  %s  %s  %s", record.Time.Local().Format(time.RFC3339), record.Scan, record.Type, record.URL)
+			if record.Type == FindingArchive && record.Confidence != nil {
+				text += fmt.Sprintf("  %s confidence %d payload %s", record.FileType, *record.Confidence, record.PayloadHash)
+			}
+			if record.New {
+				text += "  [new]"
+			}
+			emit(record, text)
+		}
+	default:
+		fmt.Fprint(out, dbUsage)
+		return fmt.Errorf("unknown db command %q", command)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package src
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Detection is the verdict of a Detector about a response.
+type Detection struct {
+	// Type is the detected file type, e.g. "zip".
+	Type string
+	// Confidence ranges from 0 (no match) to 1 (certain match).
+	Confidence float64
+	// Reject marks a false positive. A single rejection discards the
+	// candidate, regardless of what other detectors found.
+	Reject bool
+	// Mismatch is set by the scanner if the detected type does not belong
+	// to the extension of the URL, e.g. a gzip served as "backup.zip".
+	Mismatch bool
+}
+
+// Detector verifies whether a response is an exposed file of a given type.
+// It only sees the response headers and the first bytes of the body.
+type Detector interface {
+	Name() string
+	// Extensions lists the URL extensions the detector is responsible for.
+	// An empty list applies the detector to every candidate, which is what
+	// false-positive filters usually want.
+	Extensions() []string
+	Verify(header http.Header, bodyPrefix []byte) Detection
+}
+
+var (
+	detectorsMu sync.RWMutex
+	detectors   []Detector
+
+	// signatureFormats are the built-in formats recognised by their magic
+	// bytes at the start of the body.
+	signatureFormats = []signatureDetector{
+		{name: "zip", extensions: []string{"zip", "xlsx"}, magic: []byte{0x50, 0x4B, 0x03, 0x04}},
+		{name: "rar", extensions: []string{"rar"}, magic: []byte{0x52, 0x61, 0x72, 0x21}},
+		{name: "gzip", extensions: []string{"gz", "tar.gz", "sql.gz", "tgz"}, magic: []byte{0x1F, 0x8B}},
+		{name: "jpa", extensions: []string{"jpa"}, magic: []byte{0x4A, 0x50, 0x41}},
+		{name: "7z", extensions: []string{"7z"}, magic: []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C}},
+		{name: "bz2", extensions: []string{"bz2"}, magic: []byte{0x42, 0x5A, 0x68}},
+		{name: "pe", extensions: []string{"exe", "dll"}, magic: []byte{0x4D, 0x5A}},
+		{name: "ole", extensions: []string{"xls"}, magic: []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}},
+	}
+)
+
+func init() {
+	RegisterDetector(htmlFilter{})
+	RegisterDetector(tarDetector{})
+	for _, format := range signatureFormats {
+		RegisterDetector(format)
+	}
+}
+
+// RegisterDetector adds a detector to the registry used by every scan.
+func RegisterDetector(detector Detector) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	detectors = append(detectors, detector)
+}
+
+// Detectors returns all registered detectors.
+func Detectors() []Detector {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+	return append([]Detector{}, detectors...)
+}
+
+// detect sniffs the body with every registered detector, independent of the
+// URL extension. Detectors responsible for ext win over equally or less
+// confident ones that are not; a result from a foreign detector is flagged
+// as Mismatch. It reports false if nothing matched or a responsible
+// detector rejected the response.
+func detect(header http.Header, body []byte, ext string) (Detection, bool) {
+	var best Detection
+	bestApplies := false
+	if len(body) == 0 {
+		return best, false
+	}
+
+	for _, detector := range Detectors() {
+		applies := detectorApplies(detector, ext)
+		detection := detector.Verify(header, body)
+		if detection.Reject {
+			if applies {
+				return Detection{}, false
+			}
+			continue
+		}
+		if detection.Confidence <= 0 {
+			continue
+		}
+		if (applies && !bestApplies) || (applies == bestApplies && detection.Confidence > best.Confidence) {
+			best = detection
+			bestApplies = applies
+		}
+	}
+
+	best.Mismatch = best.Confidence > 0 && !bestApplies
+	return best, best.Confidence > 0
+}
+
+func detectorApplies(detector Detector, ext string) bool {
+	exts := detector.Extensions()
+	if len(exts) == 0 {
+		return true
+	}
+	for _, candidate := range exts {
+		if candidate == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlFilter rejects HTML pages, which are soft-404s or error pages.
+type htmlFilter struct{}
+
+func (htmlFilter) Name() string         { return "html-filter" }
+func (htmlFilter) Extensions() []string { return nil }
+
+func (htmlFilter) Verify(header http.Header, bodyPrefix []byte) Detection {
+	if strings.Contains(strings.ToLower(header.Get("Content-Type")), "text/html") {
+		return Detection{Reject: true}
+	}
+	lowerChunk := strings.ToLower(string(bodyPrefix))
+	if strings.Contains(lowerChunk, "<html") || strings.Contains(lowerChunk, "<!doctype") {
+		return Detection{Reject: true}
+	}
+	return Detection{}
+}
+
+// tarDetector looks for the ustar magic of the first tar header block.
+type tarDetector struct{}
+
+func (tarDetector) Name() string         { return "tar" }
+func (tarDetector) Extensions() []string { return []string{"tar"} }
+
+func (tarDetector) Verify(header http.Header, bodyPrefix []byte) Detection {
+	if len(bodyPrefix) < 512 {
+		return Detection{}
+	}
+	if bytes.Equal(bodyPrefix[257:262], []byte("ustar")) {
+		return Detection{Type: "tar", Confidence: 0.95}
+	}
+	return Detection{}
+}
+
+// signatureDetector matches a fixed magic at the start of the body. Short
+// magics are weaker evidence than long ones.
+type signatureDetector struct {
+	name       string
+	extensions []string
+	magic      []byte
+}
+
+func (d signatureDetector) Name() string         { return d.name }
+func (d signatureDetector) Extensions() []string { return d.extensions }
+
+func (d signatureDetector) Verify(header http.Header, bodyPrefix []byte) Detection {
+	if !bytes.HasPrefix(bodyPrefix, d.magic) {
+		return Detection{}
+	}
+	confidence := 0.9
+	if len(d.magic) < 4 {
+		confidence = 0.6
+	}
+	return Detection{Type: d.name, Confidence: confidence}
+}
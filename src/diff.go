@@ -0,0 +1,272 @@
+package src
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const diffUsage = `Usage: archive-finder diff [-json] [-old-scan id] [-new-scan id] <old> <new>
+
+Compares the findings of two scans by URL and payload hash. Each file is
+either written with -output or a results database (-db), of which the scan
+given by -old-scan or -new-scan is used, by default the last one. With -json
+the change list is printed as JSON lines and the summary goes to stderr.
+`
+
+// Kinds of FindingChange.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// FindingChange is a difference between the findings of two scans.
+type FindingChange struct {
+	Change string   `json:"change"`
+	URL    string   `json:"url"`
+	Type   string   `json:"type"`
+	Fields []string `json:"fields,omitempty"`
+	Old    *Finding `json:"old,omitempty"`
+	New    *Finding `json:"new,omitempty"`
+}
+
+var errResultsDB = errors.New("is a results database")
+
+// ReadFindingsFile reads the findings written by -output, one JSON line
+// each.
+func ReadFindingsFile(path string) ([]Finding, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var findings []Finding
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record struct {
+			Finding
+			Kind string `json:"kind"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if record.Kind != "" {
+			return nil, fmt.Errorf("%s %w", path, errResultsDB)
+		}
+		findings = append(findings, record.Finding)
+	}
+	return findings, scanner.Err()
+}
+
+// ReadScanFindings returns the findings of a scan stored in the results
+// database at path and the hosts the scan covered. An empty scanID or
+// "last" selects the latest scan.
+func ReadScanFindings(path string, scanID string) ([]Finding, []string, error) {
+	db := &ResultsDB{known: make(map[string]bool)}
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, err
+	}
+	if err := db.load(path); err != nil {
+		return nil, nil, err
+	}
+
+	scans := db.Scans()
+	if len(scans) == 0 {
+		return nil, nil, fmt.Errorf("%s: no scans stored", path)
+	}
+	if scanID == "" || scanID == "last" {
+		scanID = scans[len(scans)-1].ID
+	}
+	known := false
+	for _, scan := range scans {
+		known = known || scan.ID == scanID
+	}
+	if !known {
+		return nil, nil, fmt.Errorf("%s: no scan %q", path, scanID)
+	}
+
+	var findings []Finding
+	for _, record := range db.Findings(FindingFilter{Scan: scanID}) {
+		findings = append(findings, record.Finding)
+	}
+	hosts := []string{}
+	for _, record := range db.Hosts(scanID) {
+		hosts = append(hosts, record.Host)
+	}
+	return findings, hosts, nil
+}
+
+// readDiffInput reads an -output file or a scan of a results database.
+// The hosts are nil for an -output file, which does not record them.
+func readDiffInput(path string, scanID string) ([]Finding, []string, error) {
+	findings, err := ReadFindingsFile(path)
+	if errors.Is(err, errResultsDB) {
+		return ReadScanFindings(path, scanID)
+	}
+	return findings, nil, err
+}
+
+// DiffFindings compares two sets of findings by type and URL. A finding
+// present in both counts as changed if its payload hash, size or
+// Last-Modified differ. If a URL occurs several times the last one wins.
+func DiffFindings(before, after []Finding) []FindingChange {
+	key := func(f Finding) string { return string(f.Type) + "|" + f.URL }
+
+	oldByKey := make(map[string]Finding)
+	for _, f := range before {
+		oldByKey[key(f)] = f
+	}
+	newByKey := make(map[string]Finding)
+	for _, f := range after {
+		newByKey[key(f)] = f
+	}
+
+	var changes []FindingChange
+	for k, n := range newByKey {
+		n := n
+		o, ok := oldByKey[k]
+		if !ok {
+			changes = append(changes, FindingChange{Change: ChangeAdded, URL: n.URL, Type: string(n.Type), New: &n})
+			continue
+		}
+
+		var fields []string
+		if o.PayloadHash != n.PayloadHash {
+			fields = append(fields, "payload_hash")
+		}
+		if o.Size != n.Size {
+			fields = append(fields, "size")
+		}
+		if o.LastModified != n.LastModified {
+			fields = append(fields, "last_modified")
+		}
+		if len(fields) > 0 {
+			o := o
+			changes = append(changes, FindingChange{Change: ChangeChanged, URL: n.URL, Type: string(n.Type), Fields: fields, Old: &o, New: &n})
+		}
+	}
+	for k, o := range oldByKey {
+		o := o
+		if _, ok := newByKey[k]; !ok {
+			changes = append(changes, FindingChange{Change: ChangeRemoved, URL: o.URL, Type: string(o.Type), Old: &o})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Change != changes[j].Change {
+			return changes[i].Change < changes[j].Change
+		}
+		return changes[i].URL < changes[j].URL
+	})
+	return changes
+}
+
+// withoutUnscanned drops the removed findings of hosts the new scan did not
+// cover, which says nothing about them. It returns the kept changes and the
+// number dropped.
+func withoutUnscanned(changes []FindingChange, scanned []string) ([]FindingChange, int) {
+	hosts := make(map[string]bool)
+	for _, host := range scanned {
+		hosts[strings.ToLower(hostName(host))] = true
+	}
+
+	var kept []FindingChange
+	for _, change := range changes {
+		if change.Change == ChangeRemoved && !hosts[strings.ToLower(hostName(change.Old.Host))] {
+			continue
+		}
+		kept = append(kept, change)
+	}
+	return kept, len(changes) - len(kept)
+}
+
+// RunDiffCommand runs the diff subcommand with the arguments following
+// "diff". The change list goes to out, with -json the summary to errOut.
+func RunDiffCommand(args []string, out io.Writer, errOut io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	flags.Usage = func() { fmt.Fprint(errOut, diffUsage) }
+	asJSON := flags.Bool("json", false, "Print the change list as JSON lines")
+	oldScan := flags.String("old-scan", "last", "Scan of the old results database, an ID or \"last\"")
+	newScan := flags.String("new-scan", "last", "Scan of the new results database, an ID or \"last\"")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("diff needs exactly two files")
+	}
+
+	before, _, err := readDiffInput(flags.Arg(0), *oldScan)
+	if err != nil {
+		return err
+	}
+	after, scanned, err := readDiffInput(flags.Arg(1), *newScan)
+	if err != nil {
+		return err
+	}
+	changes := DiffFindings(before, after)
+	unscanned := 0
+	if scanned != nil {
+		changes, unscanned = withoutUnscanned(changes, scanned)
+	}
+
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Change]++
+		if *asJSON {
+			data, err := json.Marshal(change)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, string(data))
+			continue
+		}
+		fmt.Fprintln(out, describeChange(change))
+	}
+
+	summary := out
+	if *asJSON {
+		summary = errOut
+	}
+	fmt.Fprintf(summary, "%d added, %d removed, %d changed (%d findings before, %d after)\n",
+		counts[ChangeAdded], counts[ChangeRemoved], counts[ChangeChanged], len(before), len(after))
+	if unscanned > 0 {
+		fmt.Fprintf(summary, "%d findings of hosts not covered by the new scan ignored\n", unscanned)
+	}
+	return nil
+}
+
+func describeChange(change FindingChange) string {
+	switch change.Change {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s  %s", change.Type, change.URL)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s  %s", change.Type, change.URL)
+	}
+
+	text := fmt.Sprintf("~ %s  %s", change.Type, change.URL)
+	for _, field := range change.Fields {
+		switch field {
+		case "payload_hash":
+			text += fmt.Sprintf("  payload %s -> %s", change.Old.PayloadHash, change.New.PayloadHash)
+		case "size":
+			text += fmt.Sprintf("  size %d -> %d", change.Old.Size, change.New.Size)
+		case "last_modified":
+			text += fmt.Sprintf("  last-modified %q -> %q", change.Old.LastModified, change.New.LastModified)
+		}
+	}
+	return text
+}
@@ -0,0 +1,42 @@
+package src
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffFindings(t *testing.T) {
+	before := []Finding{
+		{Type: FindingArchive, URL: "https://a.example.com/backup.zip", Host: "a.example.com", PayloadHash: "1", Size: 10},
+		{Type: FindingArchive, URL: "https://a.example.com/old.zip", Host: "a.example.com", PayloadHash: "2"},
+		{Type: FindingArchive, URL: "https://b.example.com/site.zip", Host: "b.example.com", PayloadHash: "3"},
+	}
+	after := []Finding{
+		{Type: FindingArchive, URL: "https://a.example.com/backup.zip", Host: "a.example.com", PayloadHash: "4", Size: 12},
+		{Type: FindingBackupFile, URL: "https://a.example.com/config.php.bak", Host: "a.example.com"},
+	}
+
+	changes := DiffFindings(before, after)
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.Change+" "+change.URL)
+	}
+	want := []string{
+		"added https://a.example.com/config.php.bak",
+		"changed https://a.example.com/backup.zip",
+		"removed https://a.example.com/old.zip",
+		"removed https://b.example.com/site.zip",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if fields := changes[1].Fields; !reflect.DeepEqual(fields, []string{"payload_hash", "size"}) {
+		t.Errorf("changed fields %v", fields)
+	}
+
+	// b.example.com was not part of the new scan
+	kept, dropped := withoutUnscanned(changes, []string{"https://a.example.com"})
+	if len(kept) != 3 || dropped != 1 {
+		t.Errorf("kept %d, dropped %d changes, want 3 and 1", len(kept), dropped)
+	}
+}
@@ -0,0 +1,125 @@
+package src
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// listingFormats maps a server type to lowercase markers that all have to
+// be present in a directory listing page of that server.
+var listingFormats = []struct {
+	server  string
+	markers []string
+}{
+	{"python", []string{"<title>directory listing for /"}},
+	{"lighttpd", []string{"<title>index of /", "<div class=\"list\">"}},
+	{"apache", []string{"<title>index of /", "?c=n;o=d"}},
+	{"nginx", []string{"<title>index of /", "<h1>index of /", "<hr><pre>"}},
+	{"apache", []string{"<title>index of /", "<h1>index of /"}},
+	{"iis", []string{"[to parent directory]"}},
+	{"iis", []string{"<pre><a href=\"/\">", "&lt;dir&gt;"}},
+}
+
+// ListingResult is a directory listing found in one of the host's folders.
+type ListingResult struct {
+	URL         string
+	Server      string
+	ArchiveURLs []string
+}
+
+// findDirListings requests the root and every backup folder of a host with
+// a trailing slash and parses the ones that turn out to be directory listings.
+func findDirListings(baseURL string, folders []string, config *Config, prober Prober) []ListingResult {
+	var listings []ListingResult
+
+	for _, folder := range append([]string{""}, folders...) {
+		folderURL := baseURL
+		if folder != "" {
+			folderURL = baseURL + strings.Trim(folder, "/") + "/"
+		}
+
+		status, header, body, err := fetchPage(folderURL, config, prober)
+		if err != nil || !isSuccess(status) {
+			continue
+		}
+
+		server := detectListing(body)
+		if server == "" {
+			continue
+		}
+		if config.Verbose {
+			config.logf(LogVerbose, "url=%s listing=%s server-header=%s", folderURL, server, header.Get("Server"))
+		}
+
+		u, err := url.Parse(folderURL)
+		if err != nil {
+			continue
+		}
+		listings = append(listings, ListingResult{
+			URL:         folderURL,
+			Server:      server,
+			ArchiveURLs: listingArchiveURLs(body, u),
+		})
+	}
+
+	return listings
+}
+
+func detectListing(body []byte) string {
+	lowerChunk := strings.ToLower(string(body))
+	for _, format := range listingFormats {
+		matched := true
+		for _, marker := range format.markers {
+			if !strings.Contains(lowerChunk, marker) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return format.server
+		}
+	}
+	return ""
+}
+
+// listingArchiveURLs extracts the links of a listing that point to archive
+// files directly inside the listed folder.
+func listingArchiveURLs(body []byte, folderURL *url.URL) []string {
+	var archiveURLs []string
+	seen := make(map[string]bool)
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return archiveURLs
+		}
+		if tt != html.StartTagToken {
+			continue
+		}
+		if name, _ := tokenizer.TagName(); string(name) != "a" {
+			continue
+		}
+
+		for {
+			key, val, more := tokenizer.TagAttr()
+			if string(key) == "href" {
+				ref, err := folderURL.Parse(string(val))
+				if err == nil && ref.Host == folderURL.Host && path.Dir(ref.Path) == path.Clean(folderURL.Path) {
+					ref.RawQuery, ref.Fragment = "", ""
+					if getExtension(strings.ToLower(ref.Path)) != "" && !seen[ref.String()] {
+						seen[ref.String()] = true
+						archiveURLs = append(archiveURLs, ref.String())
+					}
+				}
+			}
+			if !more {
+				break
+			}
+		}
+	}
+}
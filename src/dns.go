@@ -0,0 +1,232 @@
+package src
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const dnsTimeout = 5 * time.Second
+
+// dnsCache holds the addresses resolved before the scan, IPv4 first.
+// Connections to a cached host try its addresses in that order, everything
+// else is resolved as usual.
+type dnsCache struct {
+	mu    sync.RWMutex
+	addrs map[string][]string
+}
+
+func (d *dnsCache) lookup(host string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.addrs[strings.ToLower(host)]
+}
+
+func (d *dnsCache) store(host string, addrs []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.addrs == nil {
+		d.addrs = make(map[string][]string)
+	}
+	d.addrs[strings.ToLower(host)] = addrs
+}
+
+// cachedAddrs replaces the host of addr by its cached addresses, if any.
+func (d *dnsCache) cachedAddrs(addr string) []string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return []string{addr}
+	}
+	addrs := d.lookup(host)
+	if len(addrs) == 0 {
+		return []string{addr}
+	}
+	result := make([]string, len(addrs))
+	for i, ip := range addrs {
+		result[i] = net.JoinHostPort(ip, port)
+	}
+	return result
+}
+
+// dial connects to the cached addresses of addr in turn, like net.Dial
+// does for the addresses of a lookup, and returns the first error if none
+// of them answers.
+func (d *dnsCache) dial(ctx context.Context, addr string, dial func(string) (net.Conn, error)) (net.Conn, error) {
+	var firstErr error
+	for _, target := range d.cachedAddrs(addr) {
+		conn, err := dial(target)
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+func (c *Config) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return c.dns.dial(ctx, addr, func(target string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, target)
+	})
+}
+
+func (c *Config) fastDial(addr string) (net.Conn, error) {
+	return c.dns.dial(context.Background(), addr, fasthttp.Dial)
+}
+
+// newResolver returns the resolver for the DNS stage. With custom resolvers
+// the queries are spread over them round-robin.
+func newResolver(servers []string) *net.Resolver {
+	if len(servers) == 0 {
+		return net.DefaultResolver
+	}
+
+	var next uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			server := servers[int(atomic.AddUint32(&next, 1))%len(servers)]
+			if _, _, err := net.SplitHostPort(server); err != nil {
+				server = net.JoinHostPort(server, "53")
+			}
+			dialer := net.Dialer{Timeout: dnsTimeout}
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// resolveHosts resolves all hosts up front, fills the cache and returns the
+// hosts that resolved. IP addresses are kept without a lookup.
+func resolveHosts(hosts []string, config *Config) []string {
+	resolver := newResolver(config.Resolvers)
+	workers := config.DNSConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	resolved := make([]bool, len(hosts))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				resolved[index] = resolveHost(hosts[index], resolver, config)
+			}
+		}()
+	}
+
+feed:
+	for i := range hosts {
+		select {
+		case indexes <- i:
+		case <-config.context().Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	var alive []string
+	for i, host := range hosts {
+		if resolved[i] {
+			alive = append(alive, host)
+		}
+	}
+
+	dropped := len(hosts) - len(alive)
+	atomic.AddInt64(&config.UnresolvedHosts, int64(dropped))
+	config.logf(LogInfo, "Resolved %d of %d hosts, dropped %d unresolvable", len(alive), len(hosts), dropped)
+	return alive
+}
+
+func resolveHost(host string, resolver *net.Resolver, config *Config) bool {
+	name := hostName(host)
+	if name == "" {
+		return false
+	}
+	if net.ParseIP(name) != nil {
+		config.dns.store(name, []string{name})
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(config.context(), dnsTimeout)
+	defer cancel()
+
+	addrs, err := resolver.LookupHost(ctx, name)
+	if err != nil || len(addrs) == 0 {
+		if config.Verbose {
+			config.logf(LogVerbose, "host=%s dropped, lookup failed: %v", name, err)
+		}
+		return false
+	}
+	sortAddrs(addrs)
+	config.dns.store(name, addrs)
+	return true
+}
+
+// sortAddrs puts IPv4 addresses first, so hosts on machines without IPv6
+// stay reachable, and otherwise sorts them to give a stable first address
+// for grouping.
+func sortAddrs(addrs []string) {
+	sort.Slice(addrs, func(i, j int) bool {
+		v4i, v4j := net.ParseIP(addrs[i]).To4() != nil, net.ParseIP(addrs[j]).To4() != nil
+		if v4i != v4j {
+			return v4i
+		}
+		return addrs[i] < addrs[j]
+	})
+}
+
+// groupByIP orders hosts so that hosts sharing an address are spread over
+// the scan instead of being scanned at the same time.
+func groupByIP(hosts []string, config *Config) []string {
+	groups := make(map[string][]string)
+	var order []string
+	for _, host := range hosts {
+		ip := ""
+		if addrs := config.dns.lookup(hostName(host)); len(addrs) > 0 {
+			ip = addrs[0]
+		}
+		if _, ok := groups[ip]; !ok {
+			order = append(order, ip)
+		}
+		groups[ip] = append(groups[ip], host)
+	}
+
+	// Take one host of every address in turn
+	result := make([]string, 0, len(hosts))
+	for len(result) < len(hosts) {
+		for _, ip := range order {
+			if group := groups[ip]; len(group) > 0 {
+				result = append(result, group[0])
+				groups[ip] = group[1:]
+			}
+		}
+	}
+
+	config.logf(LogInfo, "Grouped %d hosts by %d addresses", len(hosts), len(order))
+	return result
+}
+
+// hostName returns the bare hostname of a hosts file entry.
+func hostName(host string) string {
+	u, err := url.Parse(normalizeHost(host))
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
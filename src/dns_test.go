@@ -0,0 +1,51 @@
+package src
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestSortAddrsPutsIPv4First(t *testing.T) {
+	addrs := []string{"2606:2800:220:1:248:1893:25c8:1946", "93.184.216.34", "2001:db8::1", "10.0.0.1"}
+	sortAddrs(addrs)
+	want := []string{"10.0.0.1", "93.184.216.34", "2001:db8::1", "2606:2800:220:1:248:1893:25c8:1946"}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Errorf("got %v, want %v", addrs, want)
+	}
+}
+
+func TestDialFallsBackToNextAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	config := DefaultConfig()
+	// Nothing listens on 127.0.0.3, the connection is refused
+	config.dns.store("dual.example", []string{"127.0.0.3", "127.0.0.1"})
+
+	conn, err := config.dialContext(context.Background(), "tcp", net.JoinHostPort("dual.example", port))
+	if err != nil {
+		t.Fatalf("dialContext: %v", err)
+	}
+	conn.Close()
+
+	conn, err = config.fastDial(net.JoinHostPort("dual.example", port))
+	if err != nil {
+		t.Fatalf("fastDial: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,91 @@
+package src
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PayloadCluster groups all archive findings that served the same payload,
+// e.g. a decoy zip of a CDN or the default installer of a shared hoster.
+type PayloadCluster struct {
+	// Hash identifies the payload by its body prefix and size.
+	Hash  string
+	Size  int64
+	URLs  []string
+	Hosts []string
+}
+
+type payloadClusters struct {
+	mu       sync.Mutex
+	clusters map[string]*PayloadCluster
+}
+
+// record adds url to the cluster of key. It returns the first URL that
+// served the payload and whether url is a repeat of it.
+func (p *payloadClusters) record(key string, size int64, url string, host string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clusters == nil {
+		p.clusters = make(map[string]*PayloadCluster)
+	}
+
+	cluster, ok := p.clusters[key]
+	if !ok {
+		cluster = &PayloadCluster{Hash: shortHash(key), Size: size}
+		p.clusters[key] = cluster
+	}
+
+	for _, existing := range cluster.URLs {
+		if existing == url {
+			return cluster.URLs[0], false
+		}
+	}
+	cluster.URLs = append(cluster.URLs, url)
+	cluster.Hosts = appendUnique(cluster.Hosts, host)
+
+	return cluster.URLs[0], len(cluster.URLs) > 1
+}
+
+// list returns all clusters with more than one URL, largest first.
+func (p *payloadClusters) list() []PayloadCluster {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var result []PayloadCluster
+	for _, cluster := range p.clusters {
+		if len(cluster.URLs) < 2 {
+			continue
+		}
+		result = append(result, PayloadCluster{
+			Hash:  cluster.Hash,
+			Size:  cluster.Size,
+			URLs:  append([]string{}, cluster.URLs...),
+			Hosts: append([]string{}, cluster.Hosts...),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].URLs) != len(result[j].URLs) {
+			return len(result[i].URLs) > len(result[j].URLs)
+		}
+		return result[i].Hash < result[j].Hash
+	})
+	return result
+}
+
+// logClusters writes a summary line per duplicate payload cluster.
+func logClusters(config *Config) {
+	for _, cluster := range config.clusters.list() {
+		config.logf(LogInfo, "Duplicate payload %s (size %d) served by %d URLs on %d hosts, first: %s",
+			cluster.Hash, cluster.Size, len(cluster.URLs), len(cluster.Hosts), cluster.URLs[0])
+	}
+}
+
+func shortHash(key string) string {
+	hash, _, _ := strings.Cut(key, "/")
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
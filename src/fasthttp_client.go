@@ -1,13 +1,16 @@
 package src
 
 import (
+	"context"
 	"crypto/tls"
 	"io"
+	"net/http"
 	"time"
 
 	"github.com/valyala/fasthttp"
 )
 
+// FastHTTPClient is the fasthttp based Prober.
 type FastHTTPClient struct {
 	client *fasthttp.Client
 }
@@ -23,47 +26,93 @@ func NewFastHTTPClient(config *Config) *FastHTTPClient {
 			ReadTimeout:                   config.Timeout,
 			WriteTimeout:                  config.Timeout,
 			MaxResponseBodySize:           -1,
+			StreamResponseBody:            true,
 			TLSConfig:                     &tls.Config{InsecureSkipVerify: true},
+			Dial:                          config.fastDial,
 		},
 	}
 }
 
-func (f *FastHTTPClient) DoRequest(url string, maxBytes int64) (int, string, []byte, error) {
+func (f *FastHTTPClient) Head(ctx context.Context, targetURL string) (*ProbeResponse, error) {
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	f.prepare(req, targetURL, "HEAD")
+	resp.SkipBody = true
+
+	if err := f.do(ctx, req, resp); err != nil {
+		return nil, err
+	}
+	return &ProbeResponse{StatusCode: resp.StatusCode(), Header: fastHeader(resp)}, nil
+}
 
+func (f *FastHTTPClient) Get(ctx context.Context, targetURL string, maxBytes int) (*ProbeResponse, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
 	resp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseResponse(resp)
 
-	req.SetRequestURI(url)
-	req.Header.SetMethod("GET")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("User-Agent", GetRandomUserAgent())
-	req.Header.SetProtocol("HTTP/1.1")
+	f.prepare(req, targetURL, "GET")
+	req.Header.Set("Range", rangeHeader(maxBytes))
 
-	err := f.client.DoRedirects(req, resp, 0)
-	if err != nil {
-		return 0, "", nil, err
+	if err := f.do(ctx, req, resp); err != nil {
+		return nil, err
 	}
 
-	statusCode := resp.StatusCode()
-	contentType := string(resp.Header.Peek("Content-Type"))
+	probe := &ProbeResponse{StatusCode: resp.StatusCode(), Header: fastHeader(resp)}
 
 	stream := resp.BodyStream()
 	if stream == nil {
 		body := resp.Body()
-		if int64(len(body)) > maxBytes {
+		if len(body) > maxBytes {
 			body = body[:maxBytes]
 		}
-		return statusCode, contentType, body, nil
+		probe.Body = append([]byte(nil), body...)
+		return probe, nil
 	}
+	defer resp.CloseBodyStream()
 
 	buf := make([]byte, maxBytes)
-	n, readErr := stream.Read(buf)
+	n, err := io.ReadFull(stream, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	probe.Body = buf[:n]
+	return probe, nil
+}
+
+func (f *FastHTTPClient) prepare(req *fasthttp.Request, targetURL string, method string) {
+	req.SetRequestURI(targetURL)
+	req.Header.SetMethod(method)
+	req.Header.Set("Connection", "keep-alive")
+	req.Header.Set("User-Agent", GetRandomUserAgent())
+	req.Header.SetProtocol("HTTP/1.1")
+}
 
-	if readErr != nil && readErr != io.EOF {
-		return statusCode, contentType, nil, readErr
+// do sends the request without following redirects. fasthttp has no context
+// support, so the deadline of ctx is applied as a timeout.
+func (f *FastHTTPClient) do(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		return f.client.DoDeadline(req, resp, deadline)
 	}
+	return f.client.Do(req, resp)
+}
 
-	return statusCode, contentType, buf[:n], nil
+// fastHeader converts the response header like net/http does: names are
+// canonical and the hop-by-hop headers fasthttp fills in itself are left out.
+func fastHeader(resp *fasthttp.Response) http.Header {
+	header := make(http.Header)
+	resp.Header.VisitAll(func(key, value []byte) {
+		name := http.CanonicalHeaderKey(string(key))
+		if name == "Transfer-Encoding" || name == "Connection" {
+			return
+		}
+		header.Add(name, string(value))
+	})
+	return header
 }
@@ -0,0 +1,103 @@
+package src
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+const maxHistoryItems = 50
+
+// HistoryPaths holds what a host's historical URLs (e.g. exported from
+// Wayback or Common Crawl) reveal about its archives.
+type HistoryPaths struct {
+	ArchiveURLs []string
+	Names       []string
+	Folders     []string
+}
+
+// LoadHistoryFile reads a file with one historical URL per line and groups
+// the archive URLs, archive names and directory names by hostname.
+func LoadHistoryFile(historyFile string) (map[string]*HistoryPaths, error) {
+	file, err := os.Open(historyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	history := make(map[string]*HistoryPaths)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "http://") && !strings.HasPrefix(line, "https://") {
+			line = "https://" + line
+		}
+
+		u, err := url.Parse(line)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+
+		hostname := strings.ToLower(u.Hostname())
+		entry, ok := history[hostname]
+		if !ok {
+			entry = &HistoryPaths{}
+			history[hostname] = entry
+		}
+		addHistoryURL(entry, u)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func addHistoryURL(entry *HistoryPaths, u *url.URL) {
+	dir, file := path.Split(u.Path)
+
+	ext := getExtension(strings.ToLower(file))
+	if ext != "" {
+		archiveURL := u.Scheme + "://" + u.Host + u.EscapedPath()
+		if len(entry.ArchiveURLs) < maxHistoryItems {
+			entry.ArchiveURLs = appendUnique(entry.ArchiveURLs, archiveURL)
+		}
+
+		name := file[:len(file)-len(ext)-1]
+		if name != "" && len(entry.Names) < maxHistoryItems {
+			entry.Names = appendUnique(entry.Names, name)
+		}
+	}
+
+	folder := strings.Trim(dir, "/")
+	if folder == "" || len(folder) > maxHarvestedFolder || len(entry.Folders) >= maxHistoryItems {
+		return
+	}
+	for _, segment := range strings.Split(folder, "/") {
+		if isIrrelevantPart(segment) || numberRegex.MatchString(segment) {
+			return
+		}
+	}
+	entry.Folders = appendUnique(entry.Folders, folder)
+}
+
+// lookupHistory returns the historical paths recorded for the hostname of
+// baseURL, if any.
+func lookupHistory(baseURL string, config *Config) *HistoryPaths {
+	if config.History == nil {
+		return nil
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil
+	}
+	return config.History[strings.ToLower(u.Hostname())]
+}
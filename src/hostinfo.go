@@ -0,0 +1,52 @@
+package src
+
+import "strings"
+
+// HostInfo collects everything learned about a host before its candidate
+// paths are generated.
+type HostInfo struct {
+	Links    *HarvestedLinks
+	Profiles []*Profile
+	Seeds    *SeedPaths
+	History  *HistoryPaths
+}
+
+// gatherHostInfo runs the optional per-host discovery steps. The start page
+// is only requested once, even if several steps need it.
+func gatherHostInfo(host string, config *Config, prober Prober) *HostInfo {
+	info := &HostInfo{}
+
+	baseURL := normalizeHost(host)
+	if baseURL == "" {
+		return info
+	}
+
+	info.History = lookupHistory(baseURL, config)
+
+	if config.ModuleSeedFiles {
+		info.Seeds = fetchSeedPaths(baseURL, config, prober)
+	}
+
+	if !config.FetchHtmlFolders && !config.AutoProfile {
+		return info
+	}
+
+	page := fetchStartPage(baseURL, config, prober)
+
+	if config.FetchHtmlFolders {
+		info.Links = harvestLinks(page)
+	}
+
+	if config.AutoProfile {
+		info.Profiles = detectProfiles(page)
+		if config.Verbose && len(info.Profiles) > 0 {
+			names := make([]string, 0, len(info.Profiles))
+			for _, profile := range info.Profiles {
+				names = append(names, profile.Name)
+			}
+			config.logf(LogVerbose, "host=%s profiles=%s", host, strings.Join(names, ","))
+		}
+	}
+
+	return info
+}
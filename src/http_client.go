@@ -1,13 +1,25 @@
 package src
 
 import (
+	"context"
 	"crypto/tls"
+	"io"
 	"net/http"
 	"time"
 )
 
+// StdHTTPClient is the net/http based Prober.
+type StdHTTPClient struct {
+	client *http.Client
+}
+
+func NewStdHTTPClient(config *Config) *StdHTTPClient {
+	return &StdHTTPClient{client: NewHTTPClient(config)}
+}
+
 func NewHTTPClient(config *Config) *http.Client {
 	transport := &http.Transport{
+		DialContext:            config.dialContext,
 		MaxIdleConns:           config.Concurrency,
 		MaxIdleConnsPerHost:    config.Concurrency,
 		IdleConnTimeout:        30 * time.Second, // Reduced from 90s to minimize idle connection issues
@@ -28,3 +40,43 @@ func NewHTTPClient(config *Config) *http.Client {
 		},
 	}
 }
+
+func (s *StdHTTPClient) Head(ctx context.Context, targetURL string) (*ProbeResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", GetRandomUserAgent())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return &ProbeResponse{StatusCode: resp.StatusCode, Header: resp.Header}, nil
+}
+
+func (s *StdHTTPClient) Get(ctx context.Context, targetURL string, maxBytes int) (*ProbeResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", GetRandomUserAgent())
+	req.Header.Set("Range", rangeHeader(maxBytes))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Die ersten maxBytes Bytes lesen, ein vorzeitiges Ende ist kein Fehler
+	buf := make([]byte, maxBytes)
+	n, err := io.ReadFull(resp.Body, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	return &ProbeResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: buf[:n]}, nil
+}
@@ -98,5 +98,7 @@ func GetBasePathsAndExtensions(config *Config) ([]string, []string, []string) {
 		folders = config.BackupFolders
 	}
 
+	basePaths, extensions, folders = applyProfiles(config.Profiles, basePaths, extensions, folders)
+
 	return basePaths, extensions, folders
 }
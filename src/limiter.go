@@ -0,0 +1,223 @@
+package src
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	adaptiveStartLimit = 50
+	adaptiveMinLimit   = 10
+	adaptiveInterval   = time.Second
+	// A window with more errors or timeouts than this halves the limit
+	adaptiveMaxErrorRate   = 0.05
+	adaptiveMaxTimeoutRate = 0.02
+	// ... as does a latency this many times above the best seen so far
+	adaptiveMaxLatencyRise = 3
+)
+
+// limiter bounds the number of requests in flight. Unlike a buffered
+// channel its limit can be changed while the scan runs; waiting requests
+// are served in order.
+type limiter struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	waiters  []chan struct{}
+}
+
+func newLimiter(limit int) *limiter {
+	return &limiter{limit: limit}
+}
+
+// acquire takes a slot. It returns false once ctx is cancelled.
+func (l *limiter) acquire(ctx context.Context) bool {
+	l.mu.Lock()
+	if l.inFlight < l.limit && len(l.waiters) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return true
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return true
+	case <-ctx.Done():
+		l.mu.Lock()
+		for i, waiter := range l.waiters {
+			if waiter == ready {
+				l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+				l.mu.Unlock()
+				return false
+			}
+		}
+		l.mu.Unlock()
+		// The slot was handed over in the meantime
+		l.release()
+		return false
+	}
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.dispatch()
+}
+
+func (l *limiter) setLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.dispatch()
+}
+
+func (l *limiter) current() (limit int, inFlight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.inFlight
+}
+
+// dispatch hands free slots to waiting requests. l.mu must be held.
+func (l *limiter) dispatch() {
+	for l.inFlight < l.limit && len(l.waiters) > 0 {
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+		l.inFlight++
+	}
+}
+
+// adaptiveController adjusts the limit of a limiter AIMD-style: it starts
+// low, doubles the limit while the window is clean and the limit is used
+// up (slow start), and after the first congestion grows it additively.
+// Errors, timeouts or rising latency halve it.
+type adaptiveController struct {
+	lim       *limiter
+	max       int
+	slowStart bool
+
+	mu        sync.Mutex
+	requests  int
+	errors    int
+	timeouts  int
+	latency   time.Duration
+	saturated bool
+	best      time.Duration
+}
+
+func newAdaptiveController(lim *limiter, max int) *adaptiveController {
+	return &adaptiveController{lim: lim, max: max, slowStart: true}
+}
+
+// observe records the outcome of a single request.
+func (a *adaptiveController) observe(took time.Duration, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requests++
+	a.latency += took
+	if err != nil {
+		a.errors++
+		if isTimeout(err) {
+			a.timeouts++
+		}
+	}
+
+	if limit, inFlight := a.lim.current(); inFlight >= limit {
+		a.saturated = true
+	}
+}
+
+// run adjusts the limit once per interval until ctx is done.
+func (a *adaptiveController) run(ctx context.Context, config *Config) {
+	ticker := time.NewTicker(adaptiveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			old, _ := a.lim.current()
+			if limit := a.adjust(old); limit != old {
+				a.lim.setLimit(limit)
+				if config.Verbose {
+					config.logf(LogVerbose, "concurrency %d -> %d", old, limit)
+				}
+			}
+		}
+	}
+}
+
+// adjust evaluates the last window and returns the new limit.
+func (a *adaptiveController) adjust(limit int) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	requests, errs, timeouts, latency, saturated := a.requests, a.errors, a.timeouts, a.latency, a.saturated
+	a.requests, a.errors, a.timeouts, a.latency, a.saturated = 0, 0, 0, 0, false
+	if requests == 0 {
+		return limit
+	}
+
+	avg := latency / time.Duration(requests)
+	congested := float64(errs)/float64(requests) > adaptiveMaxErrorRate ||
+		float64(timeouts)/float64(requests) > adaptiveMaxTimeoutRate ||
+		(a.best > 0 && avg > a.best*adaptiveMaxLatencyRise)
+	if errs == 0 && (a.best == 0 || avg < a.best) {
+		a.best = avg
+	}
+
+	switch {
+	case congested:
+		a.slowStart = false
+		limit /= 2
+	case !saturated:
+		// Nothing to gain, the limit was not reached
+	case a.slowStart:
+		limit *= 2
+	default:
+		limit += adaptiveMinLimit
+	}
+
+	if limit < adaptiveMinLimit {
+		limit = adaptiveMinLimit
+	}
+	if limit > a.max {
+		limit = a.max
+	}
+	return limit
+}
+
+// observedProber reports the latency and outcome of every request of the
+// wrapped Prober to the adaptive controller of the running scan.
+type observedProber struct {
+	prober Prober
+	config *Config
+}
+
+func (o *observedProber) Head(ctx context.Context, targetURL string) (*ProbeResponse, error) {
+	start := time.Now()
+	resp, err := o.prober.Head(ctx, targetURL)
+	o.config.observe(time.Since(start), err)
+	return resp, err
+}
+
+func (o *observedProber) Get(ctx context.Context, targetURL string, maxBytes int) (*ProbeResponse, error) {
+	start := time.Now()
+	resp, err := o.prober.Get(ctx, targetURL, maxBytes)
+	o.config.observe(time.Since(start), err)
+	return resp, err
+}
+
+func (c *Config) observe(took time.Duration, err error) {
+	// Requests aborted by a cancelled scan say nothing about the load
+	if c.adaptive == nil || (err != nil && c.context().Err() != nil) {
+		return
+	}
+	c.adaptive.observe(took, err)
+}
@@ -0,0 +1,56 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestAdaptiveControllerCountsTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	config := DefaultConfig()
+	config.Timeout = 100 * time.Millisecond
+	probers := map[string]Prober{
+		"net/http": NewStdHTTPClient(config),
+		"fasthttp": NewFastHTTPClient(config),
+	}
+	for name, prober := range probers {
+		_, err := prober.Get(context.Background(), server.URL+"/slow", 2048)
+		if err == nil {
+			t.Fatalf("%s: request did not time out", name)
+		}
+		a := newAdaptiveController(newLimiter(10), 10)
+		a.observe(config.Timeout, err)
+		if a.timeouts != 1 {
+			t.Errorf("%s: %v (%T) not counted as timeout", name, err, err)
+		}
+	}
+
+	for _, err := range []error{fasthttp.ErrDialTimeout, fmt.Errorf("dial: %w", fasthttp.ErrDialTimeout), context.DeadlineExceeded} {
+		a := newAdaptiveController(newLimiter(10), 10)
+		a.observe(time.Second, err)
+		if a.timeouts != 1 {
+			t.Errorf("%v not counted as timeout", err)
+		}
+	}
+
+	a := newAdaptiveController(newLimiter(10), 10)
+	a.observe(time.Second, fasthttp.ErrConnectionClosed)
+	if a.timeouts != 0 || a.errors != 1 {
+		t.Errorf("closed connection: got %d timeouts and %d errors, want 0 and 1", a.timeouts, a.errors)
+	}
+}
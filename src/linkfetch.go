@@ -1,22 +1,156 @@
 package src
 
-func foldersFetched(host string) (folders []string) {
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
 
-	// 1) Request the host using GET and https
-	// 2) if the response is 200, parse the HTML and extract all hrefs
-	// 3) if the response is 30x, check if it stays in scope and fetch the new location
-	// 4) if the new location is 200, parse the HTML and extract all paths which contain files like images, css, styles, js, etc.
-	// 5) if the new location is 30x, stop, return empty list
+	"golang.org/x/net/html"
+)
 
-	// check if the relevant hrefs are internal links, we need to drop all irrelevant ones, we only want internal links of this host
-	// this means also dropping all external links, all links that point to a different host
+const (
+	maxHtmlRead        = 512 * 1024
+	maxHarvestedFolder = 32
+	maxHarvestedItems  = 50
+)
 
-	// what we DO NOT WANT is a list of e.g. links to posts/articles
-	//return []string{"folder1", "folder2", "folder3"} without trailing or leading slashes
+// HarvestedLinks holds the internal folders and files found in the HTML of
+// a host's start page. Files are paths relative to the root, e.g.
+// "includes/config.php".
+type HarvestedLinks struct {
+	Folders []string
+	Files   []string
+}
+
+// StartPage is the host's start page after following at most one redirect
+// that stays on the same host.
+type StartPage struct {
+	URL    *url.URL
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// fetchStartPage requests the start page of baseURL. It returns nil if the
+// host could not be reached at all.
+func fetchStartPage(baseURL string, config *Config, prober Prober) *StartPage {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil
+	}
+
+	pageURL := base
+	for hop := 0; hop < 2; hop++ {
+		status, header, body, err := fetchPage(pageURL.String(), config, prober)
+		if err != nil {
+			if config.Verbose {
+				config.logf(LogError, "HTML fetch failed for %s: %v", pageURL, err)
+			}
+			return nil
+		}
+
+		page := &StartPage{URL: pageURL, Status: status, Header: header, Body: body}
+		location := header.Get("Location")
+		if status < 300 || status >= 400 || location == "" {
+			return page
+		}
+
+		next, err := pageURL.Parse(location)
+		if err != nil || next.Hostname() != base.Hostname() {
+			return page
+		}
+		pageURL = next
+	}
+
+	return nil
+}
+
+// harvestLinks extracts the folders and file names of all internal links,
+// scripts, styles and images of the start page.
+func harvestLinks(page *StartPage) *HarvestedLinks {
+	links := &HarvestedLinks{}
+	if page == nil || !isSuccess(page.Status) {
+		return links
+	}
+	extractLinks(page.Body, page.URL, links)
+	return links
+}
+
+var errOutOfScope = errors.New("out of scope")
+
+// fetchPage requests a page, folder or seed file of a host. URLs outside
+// the scope are not requested.
+func fetchPage(pageURL string, config *Config, prober Prober) (int, http.Header, []byte, error) {
+	if !inScope(pageURL, config) {
+		if config.Verbose {
+			config.logf(LogVerbose, "url=%s skipped, out of scope", pageURL)
+		}
+		return 0, nil, nil, errOutOfScope
+	}
+	resp, err := prober.Get(config.context(), pageURL, maxHtmlRead)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return resp.StatusCode, resp.Header, resp.Body, nil
+}
+
+func extractLinks(body []byte, pageURL *url.URL, links *HarvestedLinks) {
+	seenFolders := make(map[string]bool)
+	seenFiles := make(map[string]bool)
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		for {
+			key, val, more := tokenizer.TagAttr()
+			k := string(key)
+			if k == "href" || k == "src" || k == "action" {
+				addLink(string(val), pageURL, links, seenFolders, seenFiles)
+			}
+			if !more {
+				break
+			}
+		}
+	}
+}
+
+func addLink(raw string, pageURL *url.URL, links *HarvestedLinks, seenFolders, seenFiles map[string]bool) {
+	ref, err := pageURL.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return
+	}
+	// Only internal links of the very same host are of interest
+	if ref.Hostname() != pageURL.Hostname() || (ref.Scheme != "http" && ref.Scheme != "https") {
+		return
+	}
 
-	// Also please request using sane user agents and timeouts
-	// When a folder is overly long, it should be skipped
+	dir, file := path.Split(ref.Path)
+	if file != "" && strings.Contains(file, ".") && len(links.Files) < maxHarvestedItems {
+		filePath := strings.TrimPrefix(ref.EscapedPath(), "/")
+		if !seenFiles[filePath] {
+			seenFiles[filePath] = true
+			links.Files = append(links.Files, filePath)
+		}
+	}
 
-	// Still thinking here, since its a resource waster
-	return []string{}
+	for _, folder := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if folder == "" || len(folder) > maxHarvestedFolder || isIrrelevantPart(folder) {
+			continue
+		}
+		if seenFolders[folder] || len(links.Folders) >= maxHarvestedItems {
+			continue
+		}
+		seenFolders[folder] = true
+		links.Folders = append(links.Folders, folder)
+	}
 }
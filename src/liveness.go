@@ -0,0 +1,101 @@
+package src
+
+import (
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// hostBreaker abandons a host after too many consecutive connection errors
+// or timeouts, so a dead or firewalled host does not wait out the timeout
+// for every single candidate.
+type hostBreaker struct {
+	mu     sync.Mutex
+	errors map[string]int
+	open   map[string]bool
+}
+
+// isOpen reports whether host was abandoned.
+func (b *hostBreaker) isOpen(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open[host]
+}
+
+// record counts the outcome of a request to host. It returns true exactly
+// once, when the host is abandoned by this call.
+func (b *hostBreaker) record(host string, failed bool, maxErrors int) bool {
+	if maxErrors <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.errors == nil {
+		b.errors = make(map[string]int)
+		b.open = make(map[string]bool)
+	}
+
+	if !failed {
+		b.errors[host] = 0
+		return false
+	}
+
+	b.errors[host]++
+	if b.errors[host] < maxErrors || b.open[host] {
+		return false
+	}
+	b.open[host] = true
+	return true
+}
+
+// recordRequest feeds the breaker with the outcome of a request to origin,
+// see originKey, and logs when it gets abandoned.
+func recordRequest(origin string, err error, config *Config) {
+	// A cancelled scan says nothing about the host
+	if err != nil && config.context().Err() != nil {
+		return
+	}
+	if config.breaker.record(origin, err != nil, config.MaxHostErrors) {
+		atomic.AddInt64(&config.AbandonedHosts, 1)
+		config.logf(LogVerbose, "host=%s abandoned after %d consecutive errors", origin, config.MaxHostErrors)
+	}
+}
+
+// hostAbandoned reports whether the breaker gave up on origin.
+func hostAbandoned(origin string, config *Config) bool {
+	return config.MaxHostErrors > 0 && config.breaker.isOpen(origin)
+}
+
+// tcpAlive is the cheap preflight for targets with a fixed scheme: it only
+// checks that the port accepts connections.
+func tcpAlive(target string, config *Config) bool {
+	u, err := url.Parse(normalizeHost(target))
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	dialer := net.Dialer{Timeout: preflightTimeout}
+	ctx := config.context()
+	conn, err := config.dns.dial(ctx, net.JoinHostPort(u.Hostname(), port), func(target string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "tcp", target)
+	})
+	if err != nil {
+		if config.Verbose {
+			config.logf(LogVerbose, "preflight=%s failed: %v", target, err)
+		}
+		return false
+	}
+	conn.Close()
+	return true
+}
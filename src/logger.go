@@ -18,14 +18,68 @@ func PrintWithTime(format string, a ...interface{}) {
 	fmt.Printf("[%s] %s\n", now, fmt.Sprintf(format, a...))
 }
 
-func PrintFound(archiveURL string) {
+func PrintFound(archiveURL string, confidence int) {
 	now := time.Now().Format(time.RFC3339)
 	fmt.Fprintf(
 		os.Stdout,
-		"\n[%s] %sFound archive: %s%s\n",
+		"\n[%s] %sFound archive: %s (confidence %d)%s\n",
 		now,
 		ColorGreen,
 		archiveURL,
+		confidence,
+		ColorReset,
+	)
+}
+
+func PrintFoundDuplicate(archiveURL string, firstURL string, confidence int) {
+	now := time.Now().Format(time.RFC3339)
+	fmt.Fprintf(
+		os.Stdout,
+		"\n[%s] %sFound archive: %s (confidence %d, same payload as %s)%s\n",
+		now,
+		ColorYellow,
+		archiveURL,
+		confidence,
+		firstURL,
+		ColorReset,
+	)
+}
+
+func PrintFoundMismatch(archiveURL string, detectedType string, confidence int) {
+	now := time.Now().Format(time.RFC3339)
+	fmt.Fprintf(
+		os.Stdout,
+		"\n[%s] %sFound archive: %s (detected %s, extension mismatch, confidence %d)%s\n",
+		now,
+		ColorGreen,
+		archiveURL,
+		detectedType,
+		confidence,
+		ColorReset,
+	)
+}
+
+func PrintFoundBackupFile(backupURL string) {
+	now := time.Now().Format(time.RFC3339)
+	fmt.Fprintf(
+		os.Stdout,
+		"\n[%s] %sFound backup file: %s%s\n",
+		now,
+		ColorYellow,
+		backupURL,
+		ColorReset,
+	)
+}
+
+func PrintFoundListing(listingURL string, server string) {
+	now := time.Now().Format(time.RFC3339)
+	fmt.Fprintf(
+		os.Stdout,
+		"\n[%s] %sFound directory listing (%s): %s%s\n",
+		now,
+		ColorYellow,
+		server,
+		listingURL,
 		ColorReset,
 	)
 }
@@ -0,0 +1,112 @@
+package src
+
+import (
+	"context"
+	"sync"
+)
+
+// requestPool runs the requests of all hosts on a fixed set of workers, so
+// the number of goroutines does not grow with hosts or wordlists.
+type requestPool struct {
+	jobs    chan func()
+	workers sync.WaitGroup
+}
+
+func startRequestPool(workers int) *requestPool {
+	p := &requestPool{jobs: make(chan func())}
+	for i := 0; i < workers; i++ {
+		p.workers.Add(1)
+		go func() {
+			defer p.workers.Done()
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+func (p *requestPool) stop() {
+	close(p.jobs)
+	p.workers.Wait()
+}
+
+// submit waits for a slot of sem and hands fn, which requests targetURL, to
+// the request pool, tracked by wg. It returns false once the scan is
+// cancelled. As every job holds a slot and the pool has at least as many
+// workers as sem has slots, a worker is always free once the slot is taken.
+//
+// With a per address limit its slot is taken first, so requests waiting for
+// a busy address hold up only their own host and never the slots of sem.
+func submit(config *Config, sem *limiter, wg *sync.WaitGroup, targetURL string, fn func()) bool {
+	ipLimit := config.ipLimits.forURL(targetURL, config)
+	if ipLimit != nil && !ipLimit.acquire(config.context()) {
+		return false
+	}
+	if !sem.acquire(config.context()) {
+		if ipLimit != nil {
+			ipLimit.release()
+		}
+		return false
+	}
+	wg.Add(1)
+	config.pool.jobs <- func() {
+		defer wg.Done()
+		defer sem.release()
+		if ipLimit != nil {
+			defer ipLimit.release()
+		}
+		fn()
+	}
+	return true
+}
+
+// limitedProber takes the same slots as submit for requests sent outside
+// the request pool: preflights, start pages, seed files, listings and
+// baselines of the host workers. The address slot comes first here as well.
+type limitedProber struct {
+	prober Prober
+	config *Config
+}
+
+func (p *limitedProber) Head(ctx context.Context, targetURL string) (*ProbeResponse, error) {
+	release, err := p.acquire(ctx, targetURL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return p.prober.Head(ctx, targetURL)
+}
+
+func (p *limitedProber) Get(ctx context.Context, targetURL string, maxBytes int) (*ProbeResponse, error) {
+	release, err := p.acquire(ctx, targetURL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return p.prober.Get(ctx, targetURL, maxBytes)
+}
+
+// acquire takes the slots of a request and returns the function releasing
+// them. The scan's limiter is missing outside a Scanner.
+func (p *limitedProber) acquire(ctx context.Context, targetURL string) (func(), error) {
+	ipLimit := p.config.ipLimits.forURL(targetURL, p.config)
+	if ipLimit != nil && !ipLimit.acquire(ctx) {
+		return nil, ctx.Err()
+	}
+	sem := p.config.limiter
+	if sem != nil && !sem.acquire(ctx) {
+		if ipLimit != nil {
+			ipLimit.release()
+		}
+		return nil, ctx.Err()
+	}
+	return func() {
+		if sem != nil {
+			sem.release()
+		}
+		if ipLimit != nil {
+			ipLimit.release()
+		}
+	}, nil
+}
@@ -0,0 +1,54 @@
+package src
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimitedProberTakesLimiterSlots(t *testing.T) {
+	var inFlight, peak int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			old := atomic.LoadInt64(&peak)
+			if n <= old || atomic.CompareAndSwapInt64(&peak, old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Timeout = 5 * time.Second
+	config.limiter = newLimiter(2)
+	prober := NewProber(config)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := prober.Get(context.Background(), server.URL+"/robots.txt", 2048); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("%d requests in flight, limit is 2", peak)
+	}
+	if limit, inFlight := config.limiter.current(); limit != 2 || inFlight != 0 {
+		t.Errorf("limiter left at %d/%d", inFlight, limit)
+	}
+	if pooledProber(prober) == prober {
+		t.Error("pooled prober still takes limiter slots")
+	}
+}
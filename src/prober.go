@@ -0,0 +1,66 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ProbeResponse is the part of an HTTP response the scanner looks at.
+type ProbeResponse struct {
+	StatusCode int
+	Header     http.Header
+	// Body holds at most the number of bytes requested by Get.
+	Body []byte
+}
+
+// Prober sends the requests of a scan. Redirects are never followed, a 3xx
+// answer is returned like any other response.
+type Prober interface {
+	// Head sends a HEAD request.
+	Head(ctx context.Context, targetURL string) (*ProbeResponse, error)
+	// Get sends a GET request asking for the first maxBytes bytes only and
+	// reads at most maxBytes bytes of the body.
+	Get(ctx context.Context, targetURL string, maxBytes int) (*ProbeResponse, error)
+}
+
+// NewProber returns the net/http or the fasthttp based Prober, depending on
+// config.UseFastHTTP, retrying transient failures if config.Retries is set.
+// With config.AdaptiveConcurrency every attempt feeds the concurrency
+// controller of the running scan. Every request takes a slot of the scan's
+// limiter and, with config.PerIPConcurrency, of its server address.
+// Requests of the request pool take their slots in submit instead, see
+// pooledProber.
+func NewProber(config *Config) Prober {
+	var prober Prober
+	if config.UseFastHTTP {
+		prober = NewFastHTTPClient(config)
+	} else {
+		prober = NewStdHTTPClient(config)
+	}
+	if config.AdaptiveConcurrency {
+		prober = &observedProber{prober: prober, config: config}
+	}
+	if config.Retries > 0 {
+		prober = newRetryProber(prober, config)
+	}
+	return &limitedProber{prober: prober, config: config}
+}
+
+// pooledProber returns the Prober for jobs of the request pool, which hold
+// their slots already.
+func pooledProber(prober Prober) Prober {
+	if limited, ok := prober.(*limitedProber); ok {
+		return limited.prober
+	}
+	return prober
+}
+
+func rangeHeader(maxBytes int) string {
+	return fmt.Sprintf("bytes=0-%d", maxBytes-1)
+}
+
+// isSuccess reports whether status is a full or a partial (ranged) answer.
+func isSuccess(status int) bool {
+	return status == http.StatusOK || status == http.StatusPartialContent
+}
@@ -0,0 +1,171 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var proberTestBody = bytes.Repeat([]byte("0123456789abcdef"), 256)
+
+func newProberTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write([]byte("short body"))
+	})
+	mux.HandleFunc("/ranged", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(proberTestBody))
+	})
+	mux.HandleFunc("/no-range", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(proberTestBody)
+	})
+	mux.HandleFunc("/echo-range", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Header.Get("Range")))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	return httptest.NewServer(mux)
+}
+
+func testProbers() map[string]Prober {
+	config := DefaultConfig()
+	config.Timeout = 5 * time.Second
+	config.Concurrency = 10
+	return map[string]Prober{
+		"net/http": NewStdHTTPClient(config),
+		"fasthttp": NewFastHTTPClient(config),
+	}
+}
+
+// comparableResponse drops the Date header, which differs between requests.
+func comparableResponse(resp *ProbeResponse) *ProbeResponse {
+	header := resp.Header.Clone()
+	header.Del("Date")
+	return &ProbeResponse{StatusCode: resp.StatusCode, Header: header, Body: resp.Body}
+}
+
+func TestProbersReturnIdenticalResponses(t *testing.T) {
+	server := newProberTestServer()
+	defer server.Close()
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		maxBytes   int
+		ctx        context.Context
+		wantStatus int
+		wantBody   []byte
+		wantErr    bool
+	}{
+		{name: "200", method: "GET", path: "/ok", maxBytes: 2048, wantStatus: 200, wantBody: []byte("short body")},
+		{name: "206", method: "GET", path: "/ranged", maxBytes: 100, wantStatus: 206, wantBody: proberTestBody[:100]},
+		{name: "body longer than maxBytes", method: "GET", path: "/no-range", maxBytes: 100, wantStatus: 200, wantBody: proberTestBody[:100]},
+		{name: "body shorter than maxBytes", method: "GET", path: "/ok", maxBytes: 5, wantStatus: 200, wantBody: []byte("short")},
+		{name: "range header", method: "GET", path: "/echo-range", maxBytes: 2048, wantStatus: 200, wantBody: []byte("bytes=0-2047")},
+		{name: "redirect", method: "GET", path: "/redirect", maxBytes: 2048, wantStatus: 302},
+		{name: "head redirect", method: "HEAD", path: "/redirect", wantStatus: 302},
+		{name: "head", method: "HEAD", path: "/ok", wantStatus: 200},
+		{name: "head ranged", method: "HEAD", path: "/ranged", wantStatus: 200},
+		{name: "expired context", method: "GET", path: "/ok", maxBytes: 2048, ctx: expired, wantErr: true},
+		{name: "head expired context", method: "HEAD", path: "/ok", ctx: expired, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			responses := make(map[string]*ProbeResponse)
+			for name, prober := range testProbers() {
+				var resp *ProbeResponse
+				var err error
+				if tt.method == "HEAD" {
+					resp, err = prober.Head(ctx, server.URL+tt.path)
+				} else {
+					resp, err = prober.Get(ctx, server.URL+tt.path, tt.maxBytes)
+				}
+
+				if tt.wantErr {
+					if !errors.Is(err, context.DeadlineExceeded) {
+						t.Fatalf("%s: got error %v, want %v", name, err, context.DeadlineExceeded)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("%s: got status %d, want %d", name, resp.StatusCode, tt.wantStatus)
+				}
+				if tt.wantBody != nil && !bytes.Equal(resp.Body, tt.wantBody) {
+					t.Errorf("%s: got body %q, want %q", name, resp.Body, tt.wantBody)
+				}
+				if tt.method == "HEAD" && len(resp.Body) != 0 {
+					t.Errorf("%s: HEAD returned a body of %d bytes", name, len(resp.Body))
+				}
+				if tt.wantStatus == 302 && !strings.HasSuffix(resp.Header.Get("Location"), "/ok") {
+					t.Errorf("%s: got Location %q", name, resp.Header.Get("Location"))
+				}
+				responses[name] = comparableResponse(resp)
+			}
+
+			if std, fast := responses["net/http"], responses["fasthttp"]; !reflect.DeepEqual(std, fast) {
+				t.Errorf("responses differ\nnet/http: %+v\nfasthttp: %+v", std, fast)
+			}
+		})
+	}
+}
+
+func TestIsSuccess(t *testing.T) {
+	for status, want := range map[int]bool{200: true, 206: true, 204: false, 302: false, 404: false, 416: false} {
+		if got := isSuccess(status); got != want {
+			t.Errorf("isSuccess(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+// Static file servers answer the Range header of page fetches with 206.
+func TestSeedPathsFromStaticRobots(t *testing.T) {
+	robots := []byte("User-agent: *\nDisallow: /backup/\nDisallow: /old/site.html\n")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		http.ServeContent(w, r, "robots.txt", time.Time{}, bytes.NewReader(robots))
+	}))
+	defer server.Close()
+
+	for name, prober := range testProbers() {
+		config := DefaultConfig()
+		config.SeedLimit = 10
+
+		resp, err := prober.Get(context.Background(), server.URL+"/robots.txt", maxHtmlRead)
+		if err != nil || resp.StatusCode != http.StatusPartialContent {
+			t.Fatalf("%s: got %v, %v, want a 206 answer", name, resp, err)
+		}
+
+		seeds := fetchSeedPaths(server.URL+"/", config, prober)
+		if want := []string{"backup", "old"}; !reflect.DeepEqual(seeds.Folders, want) {
+			t.Errorf("%s: got folders %v, want %v", name, seeds.Folders, want)
+		}
+	}
+}
@@ -2,19 +2,17 @@ package src
 
 import (
 	"bufio"
+	"context"
 	"math/rand"
-	"net/http"
 	"os"
-	"runtime"
 	"strings"
 	"sync"
 )
 
-func ProcessHostsFile(config *Config, stdClient *http.Client, fastClient *FastHTTPClient) error {
-
-	file, err := os.Open(config.HostsFile)
+func readHostsFile(hostsFile string) ([]string, error) {
+	file, err := os.Open(hostsFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
@@ -28,13 +26,33 @@ func ProcessHostsFile(config *Config, stdClient *http.Client, fastClient *FastHT
 	}
 
 	if err := scanner.Err(); err != nil {
-		return err
+		return nil, err
 	}
 
+	return lines, nil
+}
+
+func processHosts(hosts []string, config *Config, prober Prober) error {
+	lines := append([]string{}, hosts...)
+
 	rand.Shuffle(len(lines), func(i, j int) {
 		lines[i], lines[j] = lines[j], lines[i]
 	})
 
+	if config.ResolveHosts || len(config.Resolvers) > 0 || config.GroupByIP || config.VHostGroups || config.Scope.needsAddresses() {
+		lines = resolveHosts(lines, config)
+		// Checked after the lookup so that IP rules of the scope match too
+		lines = filterScope(lines, config)
+		if config.VHostGroups {
+			lines = groupVirtualHosts(lines, config, prober)
+		}
+		if config.GroupByIP {
+			lines = groupByIP(lines, config)
+		}
+	} else {
+		lines = filterScope(lines, config)
+	}
+
 	basePaths, extensions, backupFolders := GetBasePathsAndExtensions(config)
 
 	numBasePaths := len(basePaths)
@@ -76,13 +94,23 @@ func ProcessHostsFile(config *Config, stdClient *http.Client, fastClient *FastHT
 			dynamicEstimated += dateBasedRequests
 		}
 
+		if config.ModuleBackupFiles {
+			// Each known file combined with each backup suffix
+			dynamicEstimated += len(knownBackupFiles) * len(backupSuffixes)
+		}
+
+		if config.ModuleSeedFiles {
+			// Seed words in every folder, bounded by the seed limit
+			dynamicEstimated += config.SeedLimit * numExtensions * (1 + numBackupFolders)
+		}
+
 		estimated = numHosts * dynamicEstimated
-		PrintWithTime("Dynamic list: approximately %d requests (only dynamic entries)\n", estimated)
+		config.logf(LogInfo, "Dynamic list: approximately %d requests (only dynamic entries)", estimated)
 	} else if config.DisableDynamicEntries {
 		// Only static entries
 		staticEstimated := numBasePaths * numExtensions * numHosts
 		estimated = staticEstimated
-		PrintWithTime("Static list: %d requests (no dynamic entries)\n", estimated)
+		config.logf(LogInfo, "Static list: %d requests (no dynamic entries)", estimated)
 	} else {
 		// Both static and dynamic entries
 		staticEstimated := numBasePaths * numExtensions * numHosts
@@ -111,55 +139,234 @@ func ProcessHostsFile(config *Config, stdClient *http.Client, fastClient *FastHT
 			dynamicEstimated += dateBasedRequests
 		}
 
+		if config.ModuleBackupFiles {
+			dynamicEstimated += len(knownBackupFiles) * len(backupSuffixes)
+		}
+
+		if config.ModuleSeedFiles {
+			// Seed words in every folder, bounded by the seed limit
+			dynamicEstimated += config.SeedLimit * numExtensions * (1 + numBackupFolders)
+		}
+
 		estimated = staticEstimated + (numHosts * dynamicEstimated)
-		PrintWithTime("Complete list: approximately %d requests (static + dynamic entries)\n", estimated)
+		config.logf(LogInfo, "Complete list: approximately %d requests (static + dynamic entries)", estimated)
+	}
+
+	limit := config.Concurrency
+	if config.AdaptiveConcurrency && limit > adaptiveStartLimit {
+		limit = adaptiveStartLimit
+	}
+	config.limiter.setLimit(limit)
+	if config.AdaptiveConcurrency {
+		ctx, cancel := context.WithCancel(config.context())
+		defer cancel()
+		config.adaptive = newAdaptiveController(config.limiter, config.Concurrency)
+		go config.adaptive.run(ctx, config)
 	}
 
-	var chunk []string
-	chunkSize := config.ChunkSize
-	chunk = make([]string, 0, chunkSize)
+	config.pool = startRequestPool(config.Concurrency)
+	defer config.pool.stop()
 
-	for _, host := range lines {
-		chunk = append(chunk, host)
-		if len(chunk) >= chunkSize {
-			if err := processHostsChunk(chunk, config, stdClient, fastClient); err != nil {
-				return err
+	// Hosts enter as soon as a worker is free, so a slow host never holds up
+	// the rest. The limiter serves waiting requests in order, which
+	// interleaves the requests of all hosts in progress.
+	hostChan := make(chan string)
+	workers := config.ParallelHosts
+	if workers > len(lines) {
+		workers = len(lines)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range hostChan {
+				processHost(host, config, prober, config.limiter)
 			}
-			chunk = make([]string, 0, chunkSize)
-			runtime.GC()
+		}()
+	}
+
+feed:
+	for _, host := range lines {
+		select {
+		case hostChan <- host:
+		case <-config.context().Done():
+			break feed
 		}
 	}
+	close(hostChan)
+	wg.Wait()
 
-	if len(chunk) > 0 {
-		if err := processHostsChunk(chunk, config, stdClient, fastClient); err != nil {
-			return err
+	return config.context().Err()
+}
+
+func processHost(host string, config *Config, prober Prober, sem *limiter) {
+	group := config.vhosts[host]
+	if group != nil && group.representative == host {
+		defer close(group.done)
+	} else if group != nil {
+		// Representatives are fed first, so this never waits for a host
+		// that has not started yet
+		select {
+		case <-group.done:
+		case <-config.context().Done():
+			return
 		}
 	}
 
-	return nil
+	targets := schemeTargets(host, config, prober)
+	if len(targets) == 0 {
+		config.logf(LogVerbose, "host=%s skipped, it did not answer the preflight", host)
+		return
+	}
+	if config.db != nil && config.scan != nil {
+		if err := config.db.addHost(config.scan, host); err != nil {
+			config.logf(LogError, "Results database: %v", err)
+		}
+	}
+	// http and https of a host share its budget
+	budget := newHostBudget(host, config)
+	for _, target := range targets {
+		scanTarget(target, config, prober, sem, group, budget)
+	}
 }
 
-func processHostsChunk(hosts []string, config *Config, stdClient *http.Client, fastClient *FastHTTPClient) error {
+// scanTarget runs all modules for a host with an explicit scheme. A member
+// of a virtual host group skips what its representative requested.
+func scanTarget(host string, config *Config, prober Prober, sem *limiter, group *vhostGroup, budget *hostBudget) {
+	info := gatherHostInfo(host, config, prober)
+	baseURL := normalizeHost(host)
+	// Every candidate of this host is requested at most once
+	checked := make(map[string]bool)
+
+	member := group != nil && group.representative != host
+	candidatesOf := func(ch <-chan string) <-chan string { return ch }
+	if member {
+		candidatesOf = func(ch <-chan string) <-chan string { return group.unseen(baseURL, ch) }
+	} else if group != nil {
+		defer group.record(baseURL, checked)
+	}
+
+	var found []CheckResult
+	var listings []ListingResult
+
+	// The listings of a member are the ones of its representative
+	if config.ModuleDirListing && baseURL != "" && !member {
+		_, _, folders, _ := hostWordlists(baseURL, config, info)
+		listings = findDirListings(baseURL, folders, config, prober)
+
+		var listed []string
+		for _, listing := range listings {
+			config.report(Finding{Type: FindingDirListing, URL: listing.URL, Host: host, Detail: listing.Server})
+			listed = append(listed, listing.ArchiveURLs...)
+		}
+		found = runArchiveChecks(sliceChan(listed), config, prober, sem, checked, budget, config.FindAll)
+	}
+
+	candidates := prioritizeCandidates(candidatesOf(GenerateArchivePaths(host, config, info)), config, info)
+	found = append(found, runArchiveChecks(candidates, config, prober, sem, checked, budget, config.FindAll)...)
+
+	if config.Recursive && baseURL != "" {
+		disc := newDiscovery(baseURL, config, info, checked)
+		for _, listing := range listings {
+			if folder := strings.Trim(strings.TrimPrefix(listing.URL, baseURL), "/"); folder != "" {
+				disc.markExisting(folder)
+			}
+		}
+
+		// Follow-ups build on what was found, so they are checked and
+		// reported even after the first finding of the host
+		for depth := 0; depth < config.RecursionDepth; depth++ {
+			disc.probeFolders(found, prober, sem)
+			next := disc.followUps(found)
+			if len(next) == 0 {
+				break
+			}
+			if config.Verbose {
+				config.logf(LogVerbose, "host=%s depth=%d follow-ups=%d", host, depth+1, len(next))
+			}
+			found = runArchiveChecks(candidatesOf(sliceChan(next)), config, prober, sem, checked, budget, true)
+		}
+	}
+
+	if !config.ModuleBackupFiles {
+		return
+	}
+
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, config.Concurrency)
+	pooled := pooledProber(prober)
+	backupChan := candidatesOf(GenerateBackupFilePaths(host, config, info))
+	for backupURL := range backupChan {
+		checked[backupURL] = true
+		if !inScope(backupURL, config) {
+			continue
+		}
+		backupURL := backupURL
+		if !budget.take() || !submit(config, sem, &wg, backupURL, func() { CheckBackupFile(backupURL, pooled, config, config.Verbose) }) {
+			for range backupChan {
+			}
+			break
+		}
+	}
+	wg.Wait()
+}
 
-	for _, host := range hosts {
-		archiveChan := GenerateArchivePaths(host, config)
+// runArchiveChecks checks all candidates of ch, bounded by sem and budget,
+// and returns the archives found once every check has finished. Candidates
+// already in checked are skipped; a nil map disables the bookkeeping.
+// findAll keeps checking after the host's first finding.
+func runArchiveChecks(ch <-chan string, config *Config, prober Prober, sem *limiter, checked map[string]bool, budget *hostBudget, findAll bool) []CheckResult {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var found []CheckResult
+	pooled := pooledProber(prober)
 
-		wg.Add(1)
-		go func(ch <-chan string) {
-			defer wg.Done()
-			for archiveURL := range ch {
-				sem <- struct{}{}
-				wg.Add(1)
-				go func(url string) {
-					defer wg.Done()
-					defer func() { <-sem }()
-					CheckArchive(url, stdClient, fastClient, config, config.Verbose)
-				}(archiveURL)
+	for archiveURL := range ch {
+		if checked != nil {
+			if checked[archiveURL] {
+				continue
+			}
+			checked[archiveURL] = true
+		}
+		if !inScope(archiveURL, config) {
+			if config.Verbose {
+				config.logf(LogVerbose, "url=%s skipped, out of scope", archiveURL)
+			}
+			continue
+		}
+		archiveURL := archiveURL
+		ok := budget.take() && submit(config, sem, &wg, archiveURL, func() {
+			result := checkArchive(archiveURL, pooled, config, config.Verbose, findAll)
+			// Without a stats file the counts would be thrown away
+			if result.StatusCode != 0 && config.StatsFile != "" {
+				config.model.record(archiveURL, result.Found)
+			}
+			if result.Found {
+				mu.Lock()
+				found = append(found, result)
+				mu.Unlock()
+			}
+		})
+		if !ok {
+			// Drain the generator so its goroutine can finish
+			for range ch {
 			}
-		}(archiveChan)
+			break
+		}
 	}
 	wg.Wait()
-	return nil
+	return found
+}
+
+func sliceChan(items []string) <-chan string {
+	ch := make(chan string, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+	return ch
 }
@@ -0,0 +1,86 @@
+package src
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// BenchmarkProcessHosts scans a few hosts on a local server answering 404
+// to everything. Peak goroutines and heap should not grow with the
+// wordlist, only the number of requests does.
+func BenchmarkProcessHosts(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	var hosts []string
+	for i := 0; i < 4; i++ {
+		hosts = append(hosts, fmt.Sprintf("%s/site%d/", server.URL, i))
+	}
+
+	for _, intensity := range []string{"small", "big"} {
+		b.Run(intensity, func(b *testing.B) {
+			b.ReportAllocs()
+
+			var peakGoroutines int
+			var peakHeap uint64
+			var requests int64
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				scanner, err := NewScanner(
+					WithIntensity(intensity),
+					WithConcurrency(50),
+					WithParallelHosts(len(hosts)),
+					WithTimeout(5*time.Second),
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+				// Connections of the previous run would count as goroutines
+				server.CloseClientConnections()
+				b.StartTimer()
+
+				stop := make(chan struct{})
+				var wg sync.WaitGroup
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ticker := time.NewTicker(10 * time.Millisecond)
+					defer ticker.Stop()
+					var stats runtime.MemStats
+					for {
+						select {
+						case <-stop:
+							return
+						case <-ticker.C:
+							if n := runtime.NumGoroutine(); n > peakGoroutines {
+								peakGoroutines = n
+							}
+							runtime.ReadMemStats(&stats)
+							if stats.HeapInuse > peakHeap {
+								peakHeap = stats.HeapInuse
+							}
+						}
+					}
+				}()
+
+				if err := processHosts(hosts, scanner.config, scanner.prober); err != nil {
+					b.Fatal(err)
+				}
+				close(stop)
+				wg.Wait()
+				requests += scanner.CompletedRequests()
+			}
+
+			b.ReportMetric(float64(peakGoroutines), "peak-goroutines")
+			b.ReportMetric(float64(peakHeap), "peak-heap-B")
+			b.ReportMetric(float64(requests)/float64(b.N), "requests/op")
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Profile describes the well-known backup locations of a CMS, framework or
+// hosting panel. To select the profile automatically, HeaderMarkers are
+// matched against the start page headers including cookies and
+// AssetMarkers against the paths of its links, scripts and styles, both
+// lowercase. Page text is never matched, it may mention anything.
+type Profile struct {
+	Name          string
+	Words         []string
+	Folders       []string
+	Extensions    []string
+	HeaderMarkers []string
+	AssetMarkers  []string
+}
+
+// userPlaceholder is replaced by the likely hosting account name of a host.
+const userPlaceholder = "{user}"
+
+var builtinProfiles = map[string]*Profile{
+	"wordpress": {
+		Name:  "wordpress",
+		Words: []string{"wordpress", "wp", "wp-content", "site", "db"},
+		Folders: []string{
+			"wp-content/backups-dup-lite",
+			"wp-content/backups-dup-pro",
+			"wp-content/updraft",
+			"wp-content/ai1wm-backups",
+			"wp-content/backup-db",
+			"wp-content/backups",
+			"wp-content/uploads/backwpup",
+		},
+		Extensions:    []string{"zip", "sql.gz", "gz"},
+		HeaderMarkers: []string{"/wp-json/"},
+		AssetMarkers:  []string{"/wp-content/", "/wp-includes/"},
+	},
+	"joomla": {
+		Name:  "joomla",
+		Words: []string{"joomla", "site", "db"},
+		Folders: []string{
+			"administrator/backups",
+			"administrator/components/com_akeeba/backup",
+			"backups",
+		},
+		Extensions:   []string{"zip", "jpa", "tar.gz"},
+		AssetMarkers: []string{"/media/jui/", "/media/system/js/"},
+	},
+	"magento": {
+		Name:  "magento",
+		Words: []string{"magento", "media", "db", "code"},
+		Folders: []string{
+			"var/backups",
+			"var/backup",
+			"var",
+		},
+		Extensions:    []string{"tgz", "gz", "sql.gz", "zip"},
+		HeaderMarkers: []string{"x-magento-", "mage-cache-"},
+		AssetMarkers:  []string{"/static/version"},
+	},
+	"laravel": {
+		Name:  "laravel",
+		Words: []string{"laravel", "app", "db", "database"},
+		Folders: []string{
+			"storage/app/backups",
+			"storage/app/backup",
+			"storage/app",
+			"storage/backups",
+		},
+		Extensions:    []string{"zip", "sql.gz"},
+		HeaderMarkers: []string{"laravel_session"},
+	},
+	"plesk": {
+		Name:  "plesk",
+		Words: []string{"backup", "backup_" + userPlaceholder, "httpdocs", "dump"},
+		Folders: []string{
+			"private",
+			"dumps",
+		},
+		Extensions:    []string{"tar.gz", "tar", "zip"},
+		HeaderMarkers: []string{"x-powered-by-plesk", "plesklin", "pleskwin"},
+		AssetMarkers:  []string{"/plesk-stat/"},
+	},
+	"cpanel": {
+		Name:  "cpanel",
+		Words: []string{"cpmove-" + userPlaceholder, "backup-" + userPlaceholder, "public_html", "homedir"},
+		Folders: []string{
+			"backup",
+			"backups",
+		},
+		Extensions:    []string{"tar.gz"},
+		HeaderMarkers: []string{"cpsrvd", "cpsession", "cprelogin"},
+	},
+}
+
+// ProfileNames returns the names of all built-in profiles in sorted order.
+func ProfileNames() []string {
+	names := make([]string, 0, len(builtinProfiles))
+	for name := range builtinProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseProfiles resolves a comma-separated profile list. The keyword "auto"
+// enables fingerprint based selection instead of naming a profile.
+func parseProfiles(list string) ([]*Profile, bool, error) {
+	var profiles []*Profile
+	auto := false
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "auto" {
+			auto = true
+			continue
+		}
+		profile, ok := builtinProfiles[name]
+		if !ok {
+			return nil, false, fmt.Errorf("unknown profile %q (available: auto, %s)", name, strings.Join(ProfileNames(), ", "))
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, auto, nil
+}
+
+// detectProfiles fingerprints the start page of a host and returns every
+// built-in profile with at least one matching marker.
+func detectProfiles(page *StartPage) []*Profile {
+	if page == nil {
+		return nil
+	}
+
+	var sb strings.Builder
+	for key, values := range page.Header {
+		sb.WriteString(key)
+		sb.WriteString(": ")
+		sb.WriteString(strings.Join(values, ", "))
+		sb.WriteString("\n")
+	}
+	headers := strings.ToLower(sb.String())
+	assets := assetPaths(page.Body)
+
+	var detected []*Profile
+	for _, name := range ProfileNames() {
+		profile := builtinProfiles[name]
+		if containsAny(headers, profile.HeaderMarkers) || containsAny(assets, profile.AssetMarkers) {
+			detected = append(detected, profile)
+		}
+	}
+	return detected
+}
+
+// assetPaths returns the lowercase paths of all links, scripts, styles and
+// images of a page, one per line.
+func assetPaths(body []byte) string {
+	var sb strings.Builder
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return strings.ToLower(sb.String())
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		for {
+			key, val, more := tokenizer.TagAttr()
+			if k := string(key); k == "href" || k == "src" {
+				if ref, err := url.Parse(strings.TrimSpace(string(val))); err == nil {
+					sb.WriteString(ref.Path)
+					sb.WriteString("\n")
+				}
+			}
+			if !more {
+				break
+			}
+		}
+	}
+}
+
+func containsAny(haystack string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(haystack, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyProfiles appends the words, extensions and folders of the given
+// profiles to the existing lists, skipping duplicates.
+func applyProfiles(profiles []*Profile, basePaths, extensions, folders []string) ([]string, []string, []string) {
+	for _, profile := range profiles {
+		basePaths = appendUnique(basePaths, profile.Words...)
+		extensions = appendUnique(extensions, profile.Extensions...)
+		folders = appendUnique(folders, profile.Folders...)
+	}
+	return basePaths, extensions, folders
+}
+
+// expandPlaceholders replaces the user placeholder with the hosting account
+// name derived from baseURL. Words that cannot be expanded are dropped.
+func expandPlaceholders(words []string, baseURL string) []string {
+	user := ""
+	if u, err := url.Parse(baseURL); err == nil && !isIPAddress(u.Hostname()) {
+		if domain, err := publicsuffix.EffectiveTLDPlusOne(u.Hostname()); err == nil {
+			// cPanel and Plesk derive account names from the first 8 chars of the domain
+			user = strings.ReplaceAll(strings.SplitN(domain, ".", 2)[0], "-", "")
+			if len(user) > 8 {
+				user = user[:8]
+			}
+		}
+	}
+
+	expanded := make([]string, 0, len(words))
+	for _, word := range words {
+		if !strings.Contains(word, userPlaceholder) {
+			expanded = append(expanded, word)
+			continue
+		}
+		if user != "" {
+			expanded = append(expanded, strings.ReplaceAll(word, userPlaceholder, user))
+		}
+	}
+	return expanded
+}
+
+func appendUnique(list []string, items ...string) []string {
+	result := append([]string{}, list...)
+	for _, item := range items {
+		found := false
+		for _, existing := range result {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, item)
+		}
+	}
+	return result
+}
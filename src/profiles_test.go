@@ -0,0 +1,59 @@
+package src
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDetectProfiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   []string
+	}{
+		{
+			name: "wordpress assets",
+			body: `<link rel="stylesheet" href="/wp-content/themes/x/style.css"><script src="https://example.com/wp-includes/js/jquery.js"></script>`,
+			want: []string{"wordpress"},
+		},
+		{
+			name:   "magento header",
+			header: http.Header{"X-Magento-Cache-Debug": {"MISS"}},
+			want:   []string{"magento"},
+		},
+		{
+			name:   "laravel cookie",
+			header: http.Header{"Set-Cookie": {"XSRF-TOKEN=abc; path=/", "laravel_session=def; path=/; httponly"}},
+			want:   []string{"laravel"},
+		},
+		{
+			name:   "cpanel server",
+			header: http.Header{"Server": {"cpsrvd/11.110"}},
+			want:   []string{"cpanel"},
+		},
+		{
+			name:   "plesk",
+			header: http.Header{"X-Powered-By": {"PleskLin"}},
+			body:   `<a href="/plesk-stat/webstat/">Stats</a>`,
+			want:   []string{"plesk"},
+		},
+		{
+			name:   "text and angular cookie only",
+			header: http.Header{"Set-Cookie": {"XSRF-TOKEN=abc; path=/"}},
+			body:   `<p>We migrated from Magento to a new shop. Log in to cPanel or Plesk to manage /wp-content/ here.</p>`,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, profile := range detectProfiles(&StartPage{Header: tt.header, Body: []byte(tt.body)}) {
+			got = append(got, profile.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
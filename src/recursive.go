@@ -0,0 +1,255 @@
+package src
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dateSiblingDays   = 7
+	yearSiblingRange  = 2
+	numberSiblingStep = 3
+)
+
+var (
+	dateNameRe   = regexp.MustCompile(`(\d{4})([-_.]?)(\d{2})([-_.]?)(\d{2})`)
+	yearNameRe   = regexp.MustCompile(`(?:19|20)\d{2}`)
+	numberNameRe = regexp.MustCompile(`\d+`)
+)
+
+// discovery tracks the feedback loop of a single host: every candidate that
+// was already checked, the folders known to exist and the remaining budget.
+type discovery struct {
+	baseURL    string
+	config     *Config
+	basePaths  []string
+	extensions []string
+	folders    []string
+
+	checked  map[string]bool
+	probed   map[string]bool
+	expanded map[string]bool
+	existing []string
+	budget   int
+}
+
+func newDiscovery(baseURL string, config *Config, info *HostInfo, checked map[string]bool) *discovery {
+	basePaths, extensions, folders, _ := hostWordlists(baseURL, config, info)
+	return &discovery{
+		baseURL:    baseURL,
+		config:     config,
+		basePaths:  appendUnique(basePaths, basePathsBig...),
+		extensions: extensions,
+		folders:    folders,
+		checked:    checked,
+		probed:     make(map[string]bool),
+		expanded:   make(map[string]bool),
+		budget:     config.RecursionBudget,
+	}
+}
+
+// markExisting records a folder that is known to exist without probing it.
+func (d *discovery) markExisting(folder string) {
+	d.probed[folder] = true
+	d.existing = appendUnique(d.existing, folder)
+}
+
+// probeFolders requests every known folder that was not probed yet with a
+// trailing slash. A folder exists if it answers 200, 401 or 403 while a
+// random folder on the same host does not.
+func (d *discovery) probeFolders(found []CheckResult, prober Prober, sem *limiter) {
+	candidates := append([]string{}, d.folders...)
+	for _, result := range found {
+		if folder := d.relativeDir(result.URL); folder != "" {
+			candidates = appendUnique(candidates, folder)
+		}
+	}
+
+	var pending []string
+	for _, folder := range candidates {
+		if d.probed[folder] || d.budget <= 0 {
+			continue
+		}
+		d.probed[folder] = true
+		d.budget--
+		pending = append(pending, folder)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	baseline := probeFolderStatus(fmt.Sprintf("%s%d/", d.baseURL, time.Now().UnixNano()), d.config, prober)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	pooled := pooledProber(prober)
+	for _, folder := range pending {
+		folder := folder
+		folderURL := d.baseURL + folder + "/"
+		ok := submit(d.config, sem, &wg, folderURL, func() {
+			status := probeFolderStatus(folderURL, d.config, pooled)
+			if status == baseline || (!isSuccess(status) && status != 401 && status != 403) {
+				return
+			}
+
+			if d.config.Verbose {
+				d.config.logf(LogVerbose, "folder=%s%s/ status=%d exists", d.baseURL, folder, status)
+			}
+			mu.Lock()
+			d.existing = appendUnique(d.existing, folder)
+			mu.Unlock()
+		})
+		if !ok {
+			break
+		}
+	}
+	wg.Wait()
+}
+
+func probeFolderStatus(folderURL string, config *Config, prober Prober) int {
+	status, _, _, err := fetchPage(folderURL, config, prober)
+	if err != nil {
+		return 0
+	}
+	return status
+}
+
+// followUps generates the next wave of candidates from the archives found
+// and the folders known to exist, most promising candidates first.
+// Candidates already checked are skipped; the wave itself is marked as
+// checked when it runs.
+func (d *discovery) followUps(found []CheckResult) []string {
+	var next []string
+	queued := make(map[string]bool)
+	add := func(candidate string) {
+		if d.budget <= 0 || d.checked[candidate] || queued[candidate] {
+			return
+		}
+		queued[candidate] = true
+		d.budget--
+		next = append(next, candidate)
+	}
+
+	for _, result := range found {
+		dir := d.relativeDir(result.URL)
+		name, ext := splitArchiveName(path.Base(result.URL))
+		if name == "" {
+			continue
+		}
+
+		// 1) Siblings with the same extension in the same folder
+		for _, sibling := range nameSiblings(name) {
+			add(d.candidate(dir, sibling, ext))
+		}
+
+		// 2) The same name with all other extensions
+		for _, otherExt := range d.extensions {
+			add(d.candidate(dir, name, otherExt))
+		}
+
+		// 3) The same name under all other known folders
+		for _, folder := range append([]string{""}, d.allFolders()...) {
+			add(d.candidate(folder, name, ext))
+		}
+	}
+
+	// Folders that exist are probed with the big wordlist
+	for _, folder := range d.existing {
+		if d.expanded[folder] {
+			continue
+		}
+		d.expanded[folder] = true
+		for _, word := range d.basePaths {
+			for _, ext := range d.extensions {
+				add(d.candidate(folder, word, ext))
+			}
+		}
+	}
+
+	return next
+}
+
+func (d *discovery) allFolders() []string {
+	return appendUnique(d.existing, d.folders...)
+}
+
+func (d *discovery) candidate(folder, name, ext string) string {
+	if folder == "" {
+		return fmt.Sprintf("%s%s.%s", d.baseURL, name, ext)
+	}
+	return fmt.Sprintf("%s%s/%s.%s", d.baseURL, folder, name, ext)
+}
+
+// relativeDir returns the folder of candidateURL relative to the base URL.
+func (d *discovery) relativeDir(candidateURL string) string {
+	if !strings.HasPrefix(candidateURL, d.baseURL) {
+		return ""
+	}
+	dir := path.Dir(strings.TrimPrefix(candidateURL, d.baseURL))
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+func splitArchiveName(file string) (string, string) {
+	ext := getExtension(file)
+	if ext == "" {
+		return "", ""
+	}
+	return file[:len(file)-len(ext)-1], ext
+}
+
+// nameSiblings derives neighbouring names from a found archive name: nearby
+// dates, nearby years or incremented numbers.
+func nameSiblings(name string) []string {
+	var siblings []string
+
+	if loc := dateNameRe.FindStringSubmatchIndex(name); loc != nil {
+		layout := "2006" + name[loc[4]:loc[5]] + "01" + name[loc[8]:loc[9]] + "02"
+		if date, err := time.Parse(layout, name[loc[0]:loc[1]]); err == nil {
+			for offset := 1; offset <= dateSiblingDays; offset++ {
+				for _, day := range []time.Time{date.AddDate(0, 0, -offset), date.AddDate(0, 0, offset)} {
+					siblings = append(siblings, name[:loc[0]]+day.Format(layout)+name[loc[1]:])
+				}
+			}
+			return siblings
+		}
+	}
+
+	if loc := yearNameRe.FindStringIndex(name); loc != nil {
+		year, _ := strconv.Atoi(name[loc[0]:loc[1]])
+		for offset := 1; offset <= yearSiblingRange; offset++ {
+			for _, y := range []int{year - offset, year + offset} {
+				siblings = append(siblings, fmt.Sprintf("%s%d%s", name[:loc[0]], y, name[loc[1]:]))
+			}
+		}
+		return siblings
+	}
+
+	matches := numberNameRe.FindAllStringIndex(name, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	loc := matches[len(matches)-1]
+	digits := name[loc[0]:loc[1]]
+	number, err := strconv.Atoi(digits)
+	if err != nil {
+		return nil
+	}
+	for offset := 1; offset <= numberSiblingStep; offset++ {
+		for _, n := range []int{number - offset, number + offset} {
+			if n < 0 {
+				continue
+			}
+			// Keep zero padding such as "backup01"
+			siblings = append(siblings, fmt.Sprintf("%s%0*d%s", name[:loc[0]], len(digits), n, name[loc[1]:]))
+		}
+	}
+	return siblings
+}
@@ -0,0 +1,83 @@
+package src
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFollowUpsIgnoreFirstHitCutoff(t *testing.T) {
+	zip := append([]byte("PK\x03\x04"), bytes.Repeat([]byte{0}, 64)...)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/backup-2023.zip", "/backup-2022.zip":
+			w.Header().Set("Content-Type", "application/zip")
+			w.Write(zip)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	scanner, err := NewScanner(WithTimeout(5 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := scanner.config
+	var reported []string
+	config.OnFinding = func(f Finding) { reported = append(reported, f.URL) }
+	config.pool = startRequestPool(config.Concurrency)
+	defer config.pool.stop()
+
+	first := server.URL + "/backup-2023.zip"
+	followUp := server.URL + "/backup-2022.zip"
+	if found := runArchiveChecks(sliceChan([]string{first}), config, scanner.prober, config.limiter, nil, nil, false); len(found) != 1 {
+		t.Fatalf("first candidate: got %d findings, want 1", len(found))
+	}
+	if found := runArchiveChecks(sliceChan([]string{followUp}), config, scanner.prober, config.limiter, nil, nil, false); len(found) != 0 {
+		t.Errorf("without find-all: got %d findings after the first hit, want 0", len(found))
+	}
+	if found := runArchiveChecks(sliceChan([]string{followUp}), config, scanner.prober, config.limiter, nil, nil, true); len(found) != 1 {
+		t.Errorf("follow-up: got %d findings, want 1", len(found))
+	}
+	if len(reported) != 2 || reported[1] != followUp {
+		t.Errorf("got reported %v, want %s and %s", reported, first, followUp)
+	}
+}
+
+func TestGroupRecordsFollowUps(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	scanner, err := NewScanner(WithTimeout(5*time.Second), WithRecursive(true, 2, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := scanner.config
+	config.pool = startRequestPool(config.Concurrency)
+	defer config.pool.stop()
+
+	baseURL := server.URL + "/"
+	checked := make(map[string]bool)
+	disc := newDiscovery(baseURL, config, nil, checked)
+	found := []CheckResult{{URL: baseURL + "backup-2023.zip", Found: true}}
+
+	next := disc.followUps(found)
+	if len(next) == 0 {
+		t.Fatal("no follow-ups")
+	}
+	runArchiveChecks(sliceChan(next), config, scanner.prober, config.limiter, checked, nil, true)
+
+	group := &vhostGroup{representative: "rep", done: make(chan struct{}), paths: make(map[string]bool)}
+	group.record(baseURL, checked)
+	for _, candidate := range next {
+		if key, _ := groupPath(baseURL, candidate); !group.paths[key] {
+			t.Fatalf("follow-up %s not recorded for the group", candidate)
+		}
+	}
+	if again := disc.followUps(found); len(again) != 0 {
+		t.Errorf("follow-ups repeated %d checked candidates", len(again))
+	}
+}
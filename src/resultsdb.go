@@ -0,0 +1,255 @@
+package src
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ResultsDB is a file based store of all scans, the hosts they covered and
+// their findings. Every record is appended to the file as one JSON line
+// when it happens, so an aborted scan keeps what it found so far.
+type ResultsDB struct {
+	mu   sync.Mutex
+	file *os.File
+
+	scans    []*ScanRecord
+	hosts    []HostRecord
+	findings []FindingRecord
+	// known holds the keys of all findings stored before
+	known map[string]bool
+}
+
+// ScanRecord describes a single scan.
+type ScanRecord struct {
+	ID       string    `json:"id"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Hosts    int       `json:"hosts"`
+	Findings int       `json:"findings"`
+	New      int       `json:"new"`
+}
+
+// HostRecord tells that a scan covered a host.
+type HostRecord struct {
+	Scan string    `json:"scan"`
+	Host string    `json:"host"`
+	Time time.Time `json:"time"`
+}
+
+// FindingRecord is a finding of a scan. New is set if no earlier scan
+// reported the same URL with the same payload.
+type FindingRecord struct {
+	Scan string `json:"scan"`
+	New  bool   `json:"new"`
+	Finding
+}
+
+type dbRecord struct {
+	Kind    string         `json:"kind"`
+	Scan    *ScanRecord    `json:"scan_record,omitempty"`
+	Host    *HostRecord    `json:"host_record,omitempty"`
+	Finding *FindingRecord `json:"finding_record,omitempty"`
+}
+
+// OpenResultsDB opens or creates the results file at path.
+func OpenResultsDB(path string) (*ResultsDB, error) {
+	db := &ResultsDB{known: make(map[string]bool)}
+	if err := db.load(path); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := terminateLastLine(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	db.file = file
+	return db, nil
+}
+
+// terminateLastLine ends a line cut off by a crash, so the next record
+// starts on a line of its own.
+func terminateLastLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = file.Write([]byte("\n"))
+	return err
+}
+
+func (db *ResultsDB) load(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scans := make(map[string]*ScanRecord)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record dbRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A line cut off by a crash must not lose the rest of the file
+			continue
+		}
+		switch {
+		case record.Scan != nil:
+			// Later records of a scan update the earlier ones
+			if existing, ok := scans[record.Scan.ID]; ok {
+				*existing = *record.Scan
+				continue
+			}
+			scans[record.Scan.ID] = record.Scan
+			db.scans = append(db.scans, record.Scan)
+		case record.Host != nil:
+			db.hosts = append(db.hosts, *record.Host)
+		case record.Finding != nil:
+			db.findings = append(db.findings, *record.Finding)
+			db.known[findingKey(record.Finding.Finding)] = true
+		}
+	}
+	return scanner.Err()
+}
+
+func (db *ResultsDB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.file == nil {
+		return nil
+	}
+	err := db.file.Close()
+	db.file = nil
+	return err
+}
+
+func (db *ResultsDB) append(record dbRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = db.file.Write(append(data, '\n'))
+	return err
+}
+
+// beginScan stores a new scan and returns it.
+func (db *ResultsDB) beginScan() (*ScanRecord, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now().UTC()
+	scan := &ScanRecord{ID: now.Format("20060102T150405.000Z"), Started: now}
+	db.scans = append(db.scans, scan)
+	return scan, db.append(dbRecord{Kind: "scan", Scan: scan})
+}
+
+func (db *ResultsDB) finishScan(scan *ScanRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	scan.Finished = time.Now().UTC()
+	return db.append(dbRecord{Kind: "scan", Scan: scan})
+}
+
+func (db *ResultsDB) addHost(scan *ScanRecord, host string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	record := HostRecord{Scan: scan.ID, Host: host, Time: time.Now().UTC()}
+	scan.Hosts++
+	db.hosts = append(db.hosts, record)
+	return db.append(dbRecord{Kind: "host", Host: &record})
+}
+
+// addFinding stores a finding of scan and reports whether it is new.
+func (db *ResultsDB) addFinding(scan *ScanRecord, finding Finding) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	key := findingKey(finding)
+	record := FindingRecord{Scan: scan.ID, New: !db.known[key], Finding: finding}
+	db.known[key] = true
+	scan.Findings++
+	if record.New {
+		scan.New++
+	}
+	db.findings = append(db.findings, record)
+	return record.New, db.append(dbRecord{Kind: "finding", Finding: &record})
+}
+
+// findingKey identifies a finding across scans: an archive counts as the
+// same only while it serves the same payload.
+func findingKey(finding Finding) string {
+	return string(finding.Type) + "|" + finding.URL + "|" + finding.PayloadHash
+}
+
+// Scans returns all scans, oldest first.
+func (db *ResultsDB) Scans() []ScanRecord {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	result := make([]ScanRecord, 0, len(db.scans))
+	for _, scan := range db.scans {
+		result = append(result, *scan)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Started.Before(result[j].Started) })
+	return result
+}
+
+// Hosts returns the hosts covered by the scan with the given ID, or by all
+// scans if scanID is empty.
+func (db *ResultsDB) Hosts(scanID string) []HostRecord {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var result []HostRecord
+	for _, host := range db.hosts {
+		if scanID == "" || host.Scan == scanID {
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// FindingFilter selects findings; zero fields match everything.
+type FindingFilter struct {
+	Scan    string
+	Host    string
+	Type    FindingType
+	Since   time.Time
+	OnlyNew bool
+}
+
+// Findings returns the stored findings matching filter, oldest first.
+func (db *ResultsDB) Findings(filter FindingFilter) []FindingRecord {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var result []FindingRecord
+	for _, record := range db.findings {
+		switch {
+		case filter.Scan != "" && record.Scan != filter.Scan,
+			filter.Host != "" && hostName(record.Host) != hostName(filter.Host),
+			filter.Type != "" && record.Type != filter.Type,
+			!filter.Since.IsZero() && record.Time.Before(filter.Since),
+			filter.OnlyNew && !record.New:
+			continue
+		}
+		result = append(result, record)
+	}
+	return result
+}
@@ -0,0 +1,193 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T) (*ResultsDB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	db, err := OpenResultsDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+func TestReportMarksKnownFindings(t *testing.T) {
+	db, _ := openTestDB(t)
+	finding := Finding{Type: FindingArchive, URL: "https://example.com/backup.zip", Host: "example.com", PayloadHash: "abc"}
+
+	var got []Finding
+	config := DefaultConfig()
+	config.db = db
+	config.OnFinding = func(f Finding) { got = append(got, f) }
+
+	config.scan = &ScanRecord{ID: "first"}
+	config.report(finding)
+	config.scan = &ScanRecord{ID: "second"}
+	config.report(finding)
+	if len(got) != 2 || got[0].Known || !got[1].Known {
+		t.Fatalf("got %+v, want the second finding marked as known", got)
+	}
+
+	got = nil
+	config.OnlyNew = true
+	config.scan = &ScanRecord{ID: "third"}
+	config.report(finding)
+	if len(got) != 0 {
+		t.Errorf("only-new: got %+v, want no finding", got)
+	}
+}
+
+// writeTestScans stores two scans: the first finds a.zip and a listing, the
+// second a.zip again and b.zip.
+func writeTestScans(t *testing.T, db *ResultsDB) {
+	t.Helper()
+	now := time.Now().UTC()
+	first := &ScanRecord{ID: "scan-1", Started: now.Add(-2 * time.Hour)}
+	second := &ScanRecord{ID: "scan-2", Started: now.Add(-time.Hour)}
+	findings := []struct {
+		scan    *ScanRecord
+		finding Finding
+	}{
+		{first, Finding{Type: FindingArchive, URL: "https://a.example.com/a.zip", Host: "a.example.com", PayloadHash: "1", Time: now.Add(-2 * time.Hour)}},
+		{first, Finding{Type: FindingDirListing, URL: "https://a.example.com/backup/", Host: "a.example.com", Time: now.Add(-2 * time.Hour)}},
+		{second, Finding{Type: FindingArchive, URL: "https://a.example.com/a.zip", Host: "a.example.com", PayloadHash: "1", Time: now.Add(-time.Hour)}},
+		{second, Finding{Type: FindingArchive, URL: "https://b.example.com/b.zip", Host: "b.example.com", PayloadHash: "2", Time: now.Add(-time.Hour)}},
+	}
+
+	for _, scan := range []*ScanRecord{first, second} {
+		db.scans = append(db.scans, scan)
+		if err := db.append(dbRecord{Kind: "scan", Scan: scan}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range findings {
+		if err := db.addHost(f.scan, f.finding.Host); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.addFinding(f.scan, f.finding); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, scan := range []*ScanRecord{first, second} {
+		if err := db.finishScan(scan); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAddFindingDetectsKnownFindings(t *testing.T) {
+	db, _ := openTestDB(t)
+	scan := &ScanRecord{ID: "scan"}
+	finding := Finding{Type: FindingArchive, URL: "https://example.com/a.zip", PayloadHash: "1"}
+
+	for i, want := range []bool{true, false} {
+		isNew, err := db.addFinding(scan, finding)
+		if err != nil || isNew != want {
+			t.Errorf("call %d: got %v, %v, want %v", i+1, isNew, err, want)
+		}
+	}
+	// Another payload at the same URL is a new finding
+	finding.PayloadHash = "2"
+	if isNew, _ := db.addFinding(scan, finding); !isNew {
+		t.Error("changed payload not reported as new")
+	}
+	if scan.Findings != 3 || scan.New != 2 {
+		t.Errorf("got %d findings and %d new, want 3 and 2", scan.Findings, scan.New)
+	}
+}
+
+func TestLoadResultsDB(t *testing.T) {
+	db, path := openTestDB(t)
+	writeTestScans(t, db)
+	db.Close()
+
+	// A record cut off by a crash
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"kind":"finding","finding_rec`)
+	file.Close()
+
+	db, err = OpenResultsDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scans := db.Scans()
+	if len(scans) != 2 || scans[0].ID != "scan-1" || scans[1].Findings != 2 || scans[1].New != 1 || scans[1].Finished.IsZero() {
+		t.Errorf("got scans %+v", scans)
+	}
+	if got := len(db.Hosts("scan-2")); got != 2 {
+		t.Errorf("got %d hosts of scan-2, want 2", got)
+	}
+
+	// The next record must not be glued to the cut off line
+	if _, err := db.addFinding(&ScanRecord{ID: "scan-3"}, Finding{Type: FindingArchive, URL: "https://c.example.com/c.zip"}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	db, err = OpenResultsDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got := db.Findings(FindingFilter{Scan: "scan-3"}); len(got) != 1 {
+		t.Errorf("got %d findings of scan-3 after a cut off line, want 1", len(got))
+	}
+}
+
+func TestRunDBCommandFilters(t *testing.T) {
+	db, path := openTestDB(t)
+	writeTestScans(t, db)
+	db.Close()
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"findings"}, []string{"https://a.example.com/a.zip", "https://a.example.com/backup/", "https://a.example.com/a.zip", "https://b.example.com/b.zip"}},
+		{[]string{"findings", "-scan", "last"}, []string{"https://a.example.com/a.zip", "https://b.example.com/b.zip"}},
+		{[]string{"findings", "-scan", "scan-2", "-new"}, []string{"https://b.example.com/b.zip"}},
+		{[]string{"findings", "-host", "https://b.example.com"}, []string{"https://b.example.com/b.zip"}},
+		{[]string{"findings", "-type", "directory-listing"}, []string{"https://a.example.com/backup/"}},
+		{[]string{"findings", "-since", "90m"}, []string{"https://a.example.com/a.zip", "https://b.example.com/b.zip"}},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		args := append(append([]string{}, tt.args...), "-db", path, "-json")
+		if err := RunDBCommand(args, &out); err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var record FindingRecord
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("%v: %v in %q", tt.args, err, line)
+			}
+			got = append(got, record.URL)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.args, got, tt.want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := RunDBCommand([]string{"hosts", "-db", path, "-scan", "scan-1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(out.String(), "a.example.com"); got != 2 {
+		t.Errorf("got %d host lines of scan-1, want 2:\n%s", got, out.String())
+	}
+}
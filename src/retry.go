@@ -0,0 +1,256 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	maxRetryDelay = 30 * time.Second
+	// maxHostDelay caps the pause between two requests to a rate-limiting host.
+	maxHostDelay = 10 * time.Second
+)
+
+// retryProber retries transient failures of the wrapped Prober with
+// exponential backoff and slows down hosts that start rate-limiting.
+type retryProber struct {
+	prober  Prober
+	retries int
+	backoff time.Duration
+	pace    hostPacer
+}
+
+func newRetryProber(prober Prober, config *Config) *retryProber {
+	return &retryProber{prober: prober, retries: config.Retries, backoff: config.RetryBackoff}
+}
+
+func (r *retryProber) Head(ctx context.Context, targetURL string) (*ProbeResponse, error) {
+	return r.do(ctx, targetURL, func() (*ProbeResponse, error) {
+		return r.prober.Head(ctx, targetURL)
+	})
+}
+
+func (r *retryProber) Get(ctx context.Context, targetURL string, maxBytes int) (*ProbeResponse, error) {
+	return r.do(ctx, targetURL, func() (*ProbeResponse, error) {
+		return r.prober.Get(ctx, targetURL, maxBytes)
+	})
+}
+
+type noRetryKey struct{}
+
+// withoutRetries marks ctx so that requests sent with it are tried once,
+// e.g. for preflights that only ask whether a host answers at all.
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+func (r *retryProber) do(ctx context.Context, targetURL string, send func() (*ProbeResponse, error)) (*ProbeResponse, error) {
+	if ctx.Value(noRetryKey{}) != nil {
+		return send()
+	}
+
+	host := targetURL
+	if u, err := url.Parse(targetURL); err == nil {
+		host = u.Host
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := r.pace.wait(ctx, host); err != nil {
+			return nil, err
+		}
+
+		resp, err := send()
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			r.pace.relax(host)
+			return resp, nil
+		}
+		if err != nil && (ctx.Err() != nil || !retryableError(err)) {
+			return nil, err
+		}
+
+		delay := backoffDelay(r.backoff, attempt)
+		if err == nil {
+			// 429 and 503 are the server asking us to slow down
+			if after, ok := retryAfter(resp.Header); ok {
+				delay = after
+			}
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				r.pace.slowDown(host, r.backoff)
+			}
+		}
+
+		if attempt >= r.retries {
+			return resp, err
+		}
+		if !sleepContext(ctx, delay) {
+			if err == nil {
+				return resp, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// retryableStatus reports whether status is worth asking again later.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryableError reports whether err is a timeout or a connection dropped
+// mid-request. Refused connections, TLS and protocol errors will fail the
+// same way again.
+func retryableError(err error) bool {
+	return isTimeout(err) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, fasthttp.ErrConnectionClosed)
+}
+
+// isTimeout reports whether err is a timeout of either client.
+func isTimeout(err error) bool {
+	// fasthttp's timeout error is no net.Error, it only has Timeout
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, fasthttp.ErrDialTimeout)
+}
+
+// backoffDelay doubles base per attempt and adds up to 50% jitter.
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	delay := base << uint(attempt)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as
+// an HTTP date.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+	} else {
+		return 0, false
+	}
+
+	switch {
+	case delay < 0:
+		delay = 0
+	case delay > maxRetryDelay:
+		delay = maxRetryDelay
+	}
+	return delay, true
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// hostPacer spaces the requests to hosts that rate-limited us. A host
+// without a delay is not slowed down at all.
+type hostPacer struct {
+	mu    sync.Mutex
+	hosts map[string]*hostPace
+}
+
+type hostPace struct {
+	delay time.Duration
+	next  time.Time
+}
+
+var errPaceCancelled = errors.New("cancelled while waiting for rate-limited host")
+
+// wait blocks until the next request to host may be sent.
+func (p *hostPacer) wait(ctx context.Context, host string) error {
+	p.mu.Lock()
+	pace, ok := p.hosts[host]
+	if !ok || pace.delay == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+
+	// Reserve the next free slot for this request
+	now := time.Now()
+	slot := pace.next
+	if slot.Before(now) {
+		slot = now
+	}
+	pace.next = slot.Add(pace.delay)
+	p.mu.Unlock()
+
+	if !sleepContext(ctx, time.Until(slot)) {
+		return errPaceCancelled
+	}
+	return nil
+}
+
+// slowDown doubles the pause between requests to host, starting at delay.
+func (p *hostPacer) slowDown(host string, delay time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hosts == nil {
+		p.hosts = make(map[string]*hostPace)
+	}
+	pace, ok := p.hosts[host]
+	if !ok {
+		pace = &hostPace{}
+		p.hosts[host] = pace
+	}
+
+	pace.delay *= 2
+	if pace.delay < delay {
+		pace.delay = delay
+	}
+	if pace.delay > maxHostDelay {
+		pace.delay = maxHostDelay
+	}
+}
+
+// relax shortens the pause for host after a regular answer.
+func (p *hostPacer) relax(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pace, ok := p.hosts[host]
+	if !ok {
+		return
+	}
+	pace.delay = pace.delay * 3 / 4
+	if pace.delay < 10*time.Millisecond {
+		delete(p.hosts, host)
+	}
+}
@@ -0,0 +1,69 @@
+package src
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, true},
+		{"fasthttp timeout", fasthttp.ErrTimeout, true},
+		{"reset", &url.Error{Op: "Get", Err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, true},
+		{"eof", &url.Error{Op: "Get", Err: io.EOF}, true},
+		{"fasthttp closed", fmt.Errorf("wrapped: %w", fasthttp.ErrConnectionClosed), true},
+		{"fasthttp dial timeout", fasthttp.ErrDialTimeout, true},
+		{"refused", &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, false},
+		{"tls", &url.Error{Op: "Get", Err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}}, false},
+		{"other", errors.New("malformed HTTP response"), false},
+	}
+	for _, tt := range tests {
+		if got := retryableError(tt.err); got != tt.want {
+			t.Errorf("%s: retryableError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryProberSkipsPreflight(t *testing.T) {
+	calls := 0
+	prober := &retryProber{prober: failingProber{calls: &calls}, retries: 3}
+
+	prober.Head(withoutRetries(context.Background()), "http://example.com/")
+	if calls != 1 {
+		t.Errorf("preflight sent %d requests, want 1", calls)
+	}
+
+	calls = 0
+	prober.Head(context.Background(), "http://example.com/")
+	if calls != 4 {
+		t.Errorf("request sent %d times, want 4", calls)
+	}
+}
+
+// failingProber fails every request with a timeout.
+type failingProber struct {
+	calls *int
+}
+
+func (f failingProber) Head(ctx context.Context, targetURL string) (*ProbeResponse, error) {
+	*f.calls++
+	return nil, fasthttp.ErrTimeout
+}
+
+func (f failingProber) Get(ctx context.Context, targetURL string, maxBytes int) (*ProbeResponse, error) {
+	return f.Head(ctx, targetURL)
+}
@@ -0,0 +1,565 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// FindingType tells what kind of exposure a Finding reports.
+type FindingType string
+
+const (
+	FindingArchive    FindingType = "archive"
+	FindingBackupFile FindingType = "backup-file"
+	FindingDirListing FindingType = "directory-listing"
+)
+
+// Finding is a single verified result of a scan.
+type Finding struct {
+	Type FindingType `json:"type"`
+	URL  string      `json:"url"`
+	Host string      `json:"host"`
+	// Detail carries type specific information, e.g. the listing server type.
+	Detail string `json:"detail,omitempty"`
+	// FileType is the sniffed type of an archive, e.g. "gzip".
+	FileType string `json:"file_type,omitempty"`
+	// Mismatch is set if FileType does not match the URL extension.
+	Mismatch bool `json:"mismatch,omitempty"`
+	// Confidence rates an archive finding from 0 to 100. Backup files and
+	// directory listings are not scored and leave it nil.
+	Confidence *int `json:"confidence,omitempty"`
+	// PayloadHash identifies the served payload by body prefix and size.
+	PayloadHash string `json:"payload_hash,omitempty"`
+	// Size is the full size of an archive, -1 if the server did not tell.
+	Size int64 `json:"size,omitempty"`
+	// LastModified is the Last-Modified header of an archive, if any.
+	LastModified string `json:"last_modified,omitempty"`
+	// DuplicateOf is the first URL that served the same payload, if any.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Known is set if the results database holds the finding from an
+	// earlier scan.
+	Known bool      `json:"known,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// LogLevel classifies the messages passed to a log handler.
+type LogLevel int
+
+const (
+	LogInfo LogLevel = iota
+	LogVerbose
+	LogError
+)
+
+// Scanner scans hosts for exposed archives. It never writes to stdout on
+// its own; findings and messages are passed to the configured handlers.
+// A Scanner runs one scan at a time; starting another while one is running
+// fails with ErrScanRunning.
+type Scanner struct {
+	config  *Config
+	prober  Prober
+	running int32
+}
+
+// ErrScanRunning is returned when a Scanner is asked to scan while an
+// earlier scan has not finished.
+var ErrScanRunning = errors.New("scanner is already running a scan")
+
+// Option configures a Scanner.
+type Option func(*Config)
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) { c.Timeout = timeout }
+}
+
+func WithConcurrency(concurrency int) Option {
+	return func(c *Config) { c.Concurrency = concurrency }
+}
+
+// WithAdaptiveConcurrency starts with a low concurrency and adjusts it to
+// the observed latency, errors and timeouts, up to the configured
+// concurrency.
+func WithAdaptiveConcurrency(enabled bool) Option {
+	return func(c *Config) { c.AdaptiveConcurrency = enabled }
+}
+
+// WithParallelHosts sets the number of hosts scanned at the same time.
+func WithParallelHosts(hosts int) Option {
+	return func(c *Config) { c.ParallelHosts = hosts }
+}
+
+// WithChunkSize is the former name of WithParallelHosts.
+//
+// Deprecated: use WithParallelHosts.
+func WithChunkSize(chunkSize int) Option {
+	return WithParallelHosts(chunkSize)
+}
+
+func WithIntensity(intensity string) Option {
+	return func(c *Config) { c.Intensity = intensity }
+}
+
+func WithWords(words ...string) Option {
+	return func(c *Config) { c.UserBaseWords = words }
+}
+
+func WithExtensions(extensions ...string) Option {
+	return func(c *Config) { c.UserExtensions = extensions }
+}
+
+func WithBackupFolders(folders ...string) Option {
+	return func(c *Config) { c.BackupFolders = folders }
+}
+
+func WithFastHTTP(enabled bool) Option {
+	return func(c *Config) { c.UseFastHTTP = enabled }
+}
+
+func WithFindAll(enabled bool) Option {
+	return func(c *Config) { c.FindAll = enabled }
+}
+
+// WithMinConfidence drops archive findings scoring below minConfidence.
+func WithMinConfidence(minConfidence int) Option {
+	return func(c *Config) { c.MinConfidence = minConfidence }
+}
+
+// WithSuppressDuplicates drops archive findings whose payload was already
+// reported for another URL. They still count in PayloadClusters.
+func WithSuppressDuplicates(enabled bool) Option {
+	return func(c *Config) { c.SuppressDuplicates = enabled }
+}
+
+// WithSchemeMode selects the schemes scanned for hosts without scheme, one
+// of SchemePreferHTTPS, SchemeHTTPSOnly, SchemeHTTPOnly or SchemeBoth.
+func WithSchemeMode(mode string) Option {
+	return func(c *Config) {
+		if !validSchemeMode(mode) {
+			c.optionErr = fmt.Errorf("unknown scheme mode %q", mode)
+			return
+		}
+		c.SchemeMode = mode
+	}
+}
+
+// WithSkipDeadHosts enables the connect preflight for hosts whose scheme
+// is fixed.
+func WithSkipDeadHosts(enabled bool) Option {
+	return func(c *Config) { c.SkipDeadHosts = enabled }
+}
+
+// WithMaxHostErrors abandons a host after n consecutive connection errors
+// or timeouts; 0 disables it.
+func WithMaxHostErrors(n int) Option {
+	return func(c *Config) { c.MaxHostErrors = n }
+}
+
+// WithRetries retries timeouts, connection resets and 429/502/503/504
+// answers up to n times, starting with a delay of backoff.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Config) {
+		c.Retries = n
+		c.RetryBackoff = backoff
+	}
+}
+
+// WithResolvers resolves all hosts before the scan using the given DNS
+// servers, or the system resolver if none are given, and drops the hosts
+// that do not resolve.
+func WithResolvers(servers ...string) Option {
+	return func(c *Config) {
+		c.ResolveHosts = true
+		c.Resolvers = servers
+	}
+}
+
+// WithGroupByIP spreads hosts sharing an address over the scan. It implies
+// the DNS stage.
+func WithGroupByIP(enabled bool) Option {
+	return func(c *Config) { c.GroupByIP = enabled }
+}
+
+// WithVHostGroups scans one representative of hosts sharing an address and
+// serving the same content in full, the others only for the candidates
+// derived from their own names. It implies the DNS stage.
+func WithVHostGroups(enabled bool) Option {
+	return func(c *Config) { c.VHostGroups = enabled }
+}
+
+// WithPerIPConcurrency bounds the concurrent requests per server address;
+// 0 disables the limit.
+func WithPerIPConcurrency(n int) Option {
+	return func(c *Config) { c.PerIPConcurrency = n }
+}
+
+// WithScope restricts the scan to the hosts and URLs scope allows. IP and
+// CIDR rules imply the DNS stage.
+func WithScope(scope *Scope) Option {
+	return func(c *Config) { c.Scope = scope }
+}
+
+// WithHostBudget limits the candidate requests per host by number and by
+// time; zero values mean no limit.
+func WithHostBudget(maxRequests int, maxTime time.Duration) Option {
+	return func(c *Config) {
+		c.MaxRequestsPerHost = maxRequests
+		c.MaxTimePerHost = maxTime
+	}
+}
+
+// WithStatsFile orders candidates by the hit rates stored in statsFile and
+// updates the file with the results of the scan.
+func WithStatsFile(statsFile string) Option {
+	return func(c *Config) { c.StatsFile = statsFile }
+}
+
+// WithResultsDB records every scan, host and finding in db. The caller
+// keeps ownership of db and closes it.
+func WithResultsDB(db *ResultsDB) Option {
+	return func(c *Config) { c.db = db }
+}
+
+// WithOnlyNew reports only findings that the results database does not
+// know from an earlier scan.
+func WithOnlyNew(enabled bool) Option {
+	return func(c *Config) { c.OnlyNew = enabled }
+}
+
+// WithYears adds backup names with the current year, e.g. backup2024.zip.
+func WithYears(enabled bool) Option {
+	return func(c *Config) { c.ModuleYears = enabled }
+}
+
+// WithDate adds backup names with today's date, e.g. backup-2024-01-31.zip.
+func WithDate(enabled bool) Option {
+	return func(c *Config) { c.ModuleDate = enabled }
+}
+
+// WithHostParts adds names derived from the parts of the hostname.
+func WithHostParts(enabled bool) Option {
+	return func(c *Config) { c.ModuleDomainParts = enabled }
+}
+
+// WithFirstChars adds the first 3 and 4 characters of the first subdomain.
+func WithFirstChars(enabled bool) Option {
+	return func(c *Config) { c.ModuleFirstChars = enabled }
+}
+
+// WithFetchHTML adds the folders linked from the host's start page.
+func WithFetchHTML(enabled bool) Option {
+	return func(c *Config) { c.FetchHtmlFolders = enabled }
+}
+
+// WithSeedFiles adds up to limit words and folders per host found in
+// robots.txt, sitemap.xml and security.txt.
+func WithSeedFiles(enabled bool, limit int) Option {
+	return func(c *Config) {
+		c.ModuleSeedFiles = enabled
+		c.SeedLimit = limit
+	}
+}
+
+// WithBackupFiles checks for editor and backup copies of common files.
+func WithBackupFiles(enabled bool) Option {
+	return func(c *Config) { c.ModuleBackupFiles = enabled }
+}
+
+// WithDirListing checks for open directory listings.
+func WithDirListing(enabled bool) Option {
+	return func(c *Config) { c.ModuleDirListing = enabled }
+}
+
+// WithRecursive follows up on findings and listings for up to depth rounds
+// and budget requests per host.
+func WithRecursive(enabled bool, depth int, budget int) Option {
+	return func(c *Config) {
+		c.Recursive = enabled
+		c.RecursionDepth = depth
+		c.RecursionBudget = budget
+	}
+}
+
+func WithVerbose(enabled bool) Option {
+	return func(c *Config) { c.Verbose = enabled }
+}
+
+// WithProfiles adds built-in profiles by name, "auto" enables detection.
+func WithProfiles(names ...string) Option {
+	return func(c *Config) {
+		for _, name := range names {
+			profiles, auto, err := parseProfiles(name)
+			if err != nil {
+				c.optionErr = err
+				return
+			}
+			c.Profiles = append(c.Profiles, profiles...)
+			c.AutoProfile = c.AutoProfile || auto
+		}
+	}
+}
+
+// WithHistory sets historical URLs per hostname, see LoadHistoryFile.
+func WithHistory(history map[string]*HistoryPaths) Option {
+	return func(c *Config) { c.History = history }
+}
+
+// WithFindingHandler sets the function called for every finding. Calls are
+// serialized, the handler does not need to be safe for concurrent use.
+func WithFindingHandler(handler func(Finding)) Option {
+	return func(c *Config) { c.OnFinding = handler }
+}
+
+// WithLogHandler sets the function receiving progress, verbose and error
+// messages. Without it, all messages are discarded.
+func WithLogHandler(handler func(LogLevel, string)) Option {
+	return func(c *Config) { c.OnLog = handler }
+}
+
+// NewScanner creates a Scanner with the default settings of the CLI,
+// modified by opts.
+func NewScanner(opts ...Option) (*Scanner, error) {
+	return NewScannerWithConfig(DefaultConfig(), opts...)
+}
+
+// NewScannerWithConfig creates a Scanner based on config, e.g. one created
+// by ParseFlags, modified by opts. The Scanner takes ownership of config.
+func NewScannerWithConfig(config *Config, opts ...Option) (*Scanner, error) {
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.optionErr != nil {
+		return nil, config.optionErr
+	}
+
+	config.limiter = newLimiter(config.Concurrency)
+	config.model = &candidateModel{}
+	config.resetScanState()
+
+	return &Scanner{config: config, prober: NewProber(config)}, nil
+}
+
+// Scan scans all hosts and reports findings to the finding handler. It
+// returns when every host is done or ctx is cancelled. Every call starts
+// from scratch: found and abandoned hosts, payload clusters and counters of
+// an earlier scan are reset. Only the learned hit rates carry over.
+func (s *Scanner) Scan(ctx context.Context, hosts []string) error {
+	return s.scan(ctx, hosts, nil)
+}
+
+// scan runs a scan that passes its findings to sink as well, after the
+// finding handler.
+func (s *Scanner) scan(ctx context.Context, hosts []string, sink func(Finding)) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return ErrScanRunning
+	}
+	defer atomic.StoreInt32(&s.running, 0)
+
+	s.config.resetScanState()
+
+	if s.config.HistoryFile != "" && s.config.History == nil {
+		history, err := LoadHistoryFile(s.config.HistoryFile)
+		if err != nil {
+			return err
+		}
+		s.config.History = history
+		s.config.logf(LogInfo, "Loaded historical URLs for %d hosts", len(history))
+	}
+
+	if s.config.StatsFile != "" {
+		model, err := loadModel(s.config.StatsFile)
+		if err != nil {
+			return err
+		}
+		s.config.model = model
+	}
+	basePaths, _, backupFolders := GetBasePathsAndExtensions(s.config)
+	s.config.model.learn(append(basePaths, backupFolders...)...)
+	s.config.model.learn(append(basePathsBig, backupFoldersBig...)...)
+
+	if s.config.DBFile != "" && s.config.db == nil {
+		db, err := OpenResultsDB(s.config.DBFile)
+		if err != nil {
+			return err
+		}
+		s.config.db = db
+		defer func() {
+			db.Close()
+			s.config.db = nil
+		}()
+	}
+	if s.config.db != nil {
+		scan, err := s.config.db.beginScan()
+		if err != nil {
+			return err
+		}
+		s.config.scan = scan
+		defer func() {
+			if err := s.config.db.finishScan(scan); err != nil {
+				s.config.logf(LogError, "Results database: %v", err)
+			}
+			s.config.logf(LogInfo, "Stored scan %s: %d hosts, %d findings, %d new", scan.ID, scan.Hosts, scan.Findings, scan.New)
+			s.config.scan = nil
+		}()
+	}
+
+	if (s.config.ScopeFile != "" || s.config.ExcludeFile != "") && s.config.Scope == nil {
+		scope, err := LoadScope(s.config.ScopeFile, s.config.ExcludeFile)
+		if err != nil {
+			return err
+		}
+		s.config.Scope = scope
+	}
+
+	s.config.ctx = ctx
+	s.config.sink = sink
+	defer func() {
+		s.config.ctx = nil
+		s.config.sink = nil
+		s.config.adaptive = nil
+	}()
+
+	err := processHosts(hosts, s.config, s.prober)
+	logClusters(s.config)
+
+	if s.config.StatsFile != "" {
+		if saveErr := s.config.model.save(s.config.StatsFile); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	return err
+}
+
+// ScanFile scans all hosts listed in hostsFile, one per line.
+func (s *Scanner) ScanFile(ctx context.Context, hostsFile string) error {
+	hosts, err := readHostsFile(hostsFile)
+	if err != nil {
+		return err
+	}
+	return s.Scan(ctx, hosts)
+}
+
+// Findings runs Scan in the background and delivers the findings through a
+// channel. The error channel receives the result of Scan once it is done;
+// both channels are closed afterwards. The findings channel has to be
+// drained, otherwise the scan blocks until ctx is cancelled.
+func (s *Scanner) Findings(ctx context.Context, hosts []string) (<-chan Finding, <-chan error) {
+	findings := make(chan Finding, 64)
+	errc := make(chan error, 1)
+
+	sink := func(f Finding) {
+		select {
+		case findings <- f:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(errc)
+		defer close(findings)
+		errc <- s.scan(ctx, hosts, sink)
+	}()
+
+	return findings, errc
+}
+
+// PayloadClusters returns every payload served by more than one archive
+// URL in the current or last scan, largest cluster first.
+func (s *Scanner) PayloadClusters() []PayloadCluster {
+	return s.config.clusters.list()
+}
+
+// CompletedRequests returns the number of candidates checked so far.
+func (s *Scanner) CompletedRequests() int64 {
+	return atomic.LoadInt64(&s.config.CompletedRequests)
+}
+
+// ConcurrencyLimit returns the current number of concurrent requests
+// allowed, which changes during a scan with adaptive concurrency.
+func (s *Scanner) ConcurrencyLimit() int {
+	limit, _ := s.config.limiter.current()
+	return limit
+}
+
+// UnresolvedHosts returns the number of hosts dropped by the DNS stage.
+func (s *Scanner) UnresolvedHosts() int64 {
+	return atomic.LoadInt64(&s.config.UnresolvedHosts)
+}
+
+// GroupedHosts returns the number of hosts scanned only for their own
+// candidates because a host on the same address served the same content.
+func (s *Scanner) GroupedHosts() int64 {
+	return atomic.LoadInt64(&s.config.GroupedHosts)
+}
+
+// OutOfScopeHosts returns the number of hosts dropped by the scope.
+func (s *Scanner) OutOfScopeHosts() int64 {
+	return atomic.LoadInt64(&s.config.OutOfScopeHosts)
+}
+
+// SkippedHosts returns the number of hosts skipped because they did not
+// answer the preflight and the number abandoned after repeated errors.
+func (s *Scanner) SkippedHosts() (dead int64, abandoned int64) {
+	return atomic.LoadInt64(&s.config.DeadHosts), atomic.LoadInt64(&s.config.AbandonedHosts)
+}
+
+// resetScanState clears everything a scan learns about its hosts.
+func (c *Config) resetScanState() {
+	c.FoundHostsMu.Lock()
+	c.FoundHosts = make(map[string]bool)
+	c.FoundBackupHosts = make(map[string]bool)
+	c.FoundHostsMu.Unlock()
+
+	c.scores = scoreState{}
+	c.clusters = payloadClusters{}
+	c.breaker = hostBreaker{}
+	c.dns = dnsCache{}
+	c.vhosts = nil
+
+	atomic.StoreInt64(&c.CompletedRequests, 0)
+	atomic.StoreInt64(&c.DeadHosts, 0)
+	atomic.StoreInt64(&c.AbandonedHosts, 0)
+	atomic.StoreInt64(&c.UnresolvedHosts, 0)
+	atomic.StoreInt64(&c.GroupedHosts, 0)
+	atomic.StoreInt64(&c.OutOfScopeHosts, 0)
+}
+
+func (c *Config) report(finding Finding) {
+	if finding.Time.IsZero() {
+		finding.Time = time.Now()
+	}
+	c.reportMu.Lock()
+	defer c.reportMu.Unlock()
+	if c.db != nil && c.scan != nil {
+		isNew, err := c.db.addFinding(c.scan, finding)
+		if err != nil {
+			c.logf(LogError, "Results database: %v", err)
+		}
+		finding.Known = !isNew
+		if finding.Known && c.OnlyNew {
+			return
+		}
+	}
+	if c.OnFinding != nil {
+		c.OnFinding(finding)
+	}
+	if c.sink != nil {
+		c.sink(finding)
+	}
+}
+
+func (c *Config) logf(level LogLevel, format string, a ...interface{}) {
+	if c.OnLog != nil {
+		c.OnLog(level, fmt.Sprintf(format, a...))
+	}
+}
+
+// context returns the context of the running scan.
+func (c *Config) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
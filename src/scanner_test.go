@@ -0,0 +1,101 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFindingConfidenceJSON(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		finding Finding
+		want    string
+	}{
+		{Finding{Type: FindingArchive, URL: "https://example.com/a.zip", Confidence: &zero}, `"confidence":0`},
+		{Finding{Type: FindingBackupFile, URL: "https://example.com/config.php.bak"}, ""},
+		{Finding{Type: FindingDirListing, URL: "https://example.com/backup/"}, ""},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.finding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		has := strings.Contains(string(data), `"confidence"`)
+		if tt.want == "" && has {
+			t.Errorf("%s: unscored finding has a confidence: %s", tt.finding.Type, data)
+		}
+		if tt.want != "" && !strings.Contains(string(data), tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.finding.Type, data, tt.want)
+		}
+	}
+}
+
+func TestFindingsUsesPerCallHandler(t *testing.T) {
+	zip := append([]byte("PK\x03\x04"), bytes.Repeat([]byte{0}, 64)...)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/backup.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		<-release
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(zip)
+	}))
+	defer server.Close()
+
+	var handled int64
+	scanner, err := NewScanner(
+		WithTimeout(5*time.Second),
+		WithSchemeMode(SchemeHTTPOnly),
+		WithWords("backup"),
+		WithExtensions("zip"),
+		WithBackupFolders(),
+		WithFindingHandler(func(Finding) { atomic.AddInt64(&handled, 1) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	first, firstErr := scanner.Findings(context.Background(), []string{host})
+	// Wait until the first scan is running
+	for atomic.LoadInt32(&scanner.running) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second, secondErr := scanner.Findings(context.Background(), []string{host})
+	if err := <-secondErr; !errors.Is(err, ErrScanRunning) {
+		t.Errorf("concurrent scan: got %v, want %v", err, ErrScanRunning)
+	}
+	for range second {
+		t.Error("concurrent scan delivered a finding")
+	}
+
+	close(release)
+	var received int
+	for range first {
+		received++
+	}
+	if err := <-firstErr; err != nil {
+		t.Fatal(err)
+	}
+	if received != 1 || handled != 1 {
+		t.Errorf("got %d findings on the channel and %d in the handler, want 1 each", received, handled)
+	}
+
+	// A later Scan only calls the handler
+	if err := scanner.Scan(context.Background(), []string{host}); err != nil {
+		t.Fatal(err)
+	}
+	if handled != 2 {
+		t.Errorf("handler called %d times, want 2", handled)
+	}
+}
@@ -0,0 +1,106 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Scheme modes decide which of http and https are scanned for hosts given
+// without a scheme. Hosts with an explicit scheme are always used as is.
+const (
+	SchemeHTTPSOnly   = "https-only"
+	SchemeHTTPOnly    = "http-only"
+	SchemeBoth        = "both"
+	SchemePreferHTTPS = "prefer-https"
+)
+
+const preflightTimeout = 10 * time.Second
+
+var schemeModes = []string{SchemePreferHTTPS, SchemeHTTPSOnly, SchemeHTTPOnly, SchemeBoth}
+
+func validSchemeMode(mode string) bool {
+	for _, valid := range schemeModes {
+		if mode == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// schemeTargets returns the hosts to scan for host, each with an explicit
+// scheme. For the both and prefer-https modes a preflight request decides
+// which schemes are alive, for fixed schemes a connect does if
+// SkipDeadHosts is set; an empty result means the host is dead.
+func schemeTargets(host string, config *Config, prober Prober) []string {
+	targets := aliveTargets(host, config, prober)
+	if len(targets) == 0 {
+		atomic.AddInt64(&config.DeadHosts, 1)
+	}
+	return targets
+}
+
+func aliveTargets(host string, config *Config, prober Prober) []string {
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return fixedTarget(host, config)
+	}
+
+	switch config.SchemeMode {
+	case SchemeHTTPOnly:
+		return fixedTarget("http://"+host, config)
+	case SchemeBoth:
+		httpsAlive := make(chan bool, 1)
+		go func() { httpsAlive <- schemeAlive("https://"+host, config, prober) }()
+		httpAlive := schemeAlive("http://"+host, config, prober)
+
+		var targets []string
+		if <-httpsAlive {
+			targets = append(targets, "https://"+host)
+		}
+		if httpAlive {
+			targets = append(targets, "http://"+host)
+		}
+		return targets
+	case SchemePreferHTTPS:
+		if schemeAlive("https://"+host, config, prober) {
+			return []string{"https://" + host}
+		}
+		if schemeAlive("http://"+host, config, prober) {
+			return []string{"http://" + host}
+		}
+		return nil
+	default:
+		return fixedTarget("https://"+host, config)
+	}
+}
+
+func fixedTarget(target string, config *Config) []string {
+	if config.SkipDeadHosts && !tcpAlive(target, config) {
+		return nil
+	}
+	return []string{target}
+}
+
+// schemeAlive reports whether the host answers on the scheme of target at
+// all. Any HTTP status counts, only connection errors and timeouts do not.
+func schemeAlive(target string, config *Config, prober Prober) bool {
+	baseURL := normalizeHost(target)
+	if baseURL == "" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(config.context(), preflightTimeout)
+	defer cancel()
+
+	_, err := prober.Head(withoutRetries(ctx), baseURL)
+	if err != nil && config.Verbose {
+		config.logf(LogVerbose, "preflight=%s failed: %v", baseURL, err)
+	}
+	return err == nil
+}
+
+func schemeModeUsage() string {
+	return fmt.Sprintf("Schemes to scan for hosts without scheme: %s", strings.Join(schemeModes, ", "))
+}
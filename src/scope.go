@@ -0,0 +1,189 @@
+package src
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Scope decides which hosts and URLs may be requested. A host or URL is in
+// scope if it matches one of the allow rules, or if there are none, and
+// matches none of the exclude rules.
+type Scope struct {
+	allow   []scopeRule
+	exclude []scopeRule
+}
+
+// scopeRule is one line of a scope file: a domain, a wildcard domain like
+// *.example.com, an IP address or CIDR, or a regex prefixed with re:.
+type scopeRule struct {
+	domain   string
+	wildcard bool
+	network  *net.IPNet
+	re       *regexp.Regexp
+}
+
+// LoadScope reads the allow and exclude rules from the given files; either
+// path may be empty.
+func LoadScope(scopeFile, excludeFile string) (*Scope, error) {
+	scope := &Scope{}
+	var err error
+	if scopeFile != "" {
+		if scope.allow, err = readScopeFile(scopeFile); err != nil {
+			return nil, err
+		}
+	}
+	if excludeFile != "" {
+		if scope.exclude, err = readScopeFile(excludeFile); err != nil {
+			return nil, err
+		}
+	}
+	return scope, nil
+}
+
+func readScopeFile(path string) ([]scopeRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []scopeRule
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := parseScopeRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+func parseScopeRule(text string) (scopeRule, error) {
+	if strings.HasPrefix(text, "re:") {
+		re, err := regexp.Compile(strings.TrimPrefix(text, "re:"))
+		return scopeRule{re: re}, err
+	}
+	if _, network, err := net.ParseCIDR(text); err == nil {
+		return scopeRule{network: network}, nil
+	}
+	if ip := net.ParseIP(text); ip != nil {
+		bits := 8 * len(ip.To16())
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return scopeRule{network: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+	}
+
+	// Entries may be given as URLs, only the host name counts
+	if name := hostName(text); name != "" {
+		text = name
+	}
+	domain := strings.ToLower(strings.TrimSuffix(text, "."))
+	if strings.HasPrefix(domain, "*.") {
+		return scopeRule{domain: strings.TrimPrefix(domain, "*."), wildcard: true}, nil
+	}
+	return scopeRule{domain: domain}, nil
+}
+
+// needsAddresses reports whether the scope has IP or CIDR rules, which
+// match host names only by their resolved addresses.
+func (s *Scope) needsAddresses() bool {
+	if s == nil {
+		return false
+	}
+	for _, rule := range append(append([]scopeRule{}, s.allow...), s.exclude...) {
+		if rule.network != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether the rule matches u, whose host resolved to addrs.
+func (r scopeRule) match(u *url.URL, addrs []string) bool {
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case r.re != nil:
+		return r.re.MatchString(host) || r.re.MatchString(u.String())
+	case r.network != nil:
+		if ip := net.ParseIP(host); ip != nil {
+			return r.network.Contains(ip)
+		}
+		for _, addr := range addrs {
+			if ip := net.ParseIP(addr); ip != nil && r.network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	case r.wildcard:
+		return strings.HasSuffix(host, "."+r.domain)
+	default:
+		return host == r.domain
+	}
+}
+
+// Allows reports whether rawURL, or a hosts file entry, is in scope. IP
+// rules also match the addresses the host resolved to.
+func (s *Scope) Allows(rawURL string, addrs []string) bool {
+	if s == nil {
+		return true
+	}
+	target := rawURL
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = "https://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+
+	allowed := len(s.allow) == 0
+	for _, rule := range s.allow {
+		if rule.match(u, addrs) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false
+	}
+	for _, rule := range s.exclude {
+		if rule.match(u, addrs) {
+			return false
+		}
+	}
+	return true
+}
+
+// inScope checks a host or URL against the scope, using the addresses of
+// the DNS stage if the host was resolved.
+func inScope(rawURL string, config *Config) bool {
+	return config.Scope.Allows(rawURL, config.dns.lookup(hostName(rawURL)))
+}
+
+// filterScope drops the hosts outside the scope.
+func filterScope(hosts []string, config *Config) []string {
+	var result []string
+	for _, host := range hosts {
+		if inScope(host, config) {
+			result = append(result, host)
+			continue
+		}
+		atomic.AddInt64(&config.OutOfScopeHosts, 1)
+		if config.Verbose {
+			config.logf(LogVerbose, "host=%s dropped, out of scope", host)
+		}
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package src
+
+import "testing"
+
+func TestScopeRules(t *testing.T) {
+	allow := []scopeRule{}
+	for _, text := range []string{"10.0.0.0/8", "*.example.com"} {
+		rule, err := parseScopeRule(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allow = append(allow, rule)
+	}
+	exclude, err := parseScopeRule(`re:/private/`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := &Scope{allow: allow, exclude: []scopeRule{exclude}}
+
+	tests := []struct {
+		url   string
+		addrs []string
+		want  bool
+	}{
+		{"https://www.example.com/backup.zip", nil, true},
+		{"https://www.example.com/private/backup.zip", nil, false},
+		{"https://intranet.corp/backup.zip", []string{"10.1.2.3"}, true},
+		{"https://intranet.corp/backup.zip", nil, false},
+		{"https://10.1.2.3/backup.zip", nil, true},
+		{"other.org", []string{"192.168.0.1"}, false},
+	}
+	for _, tt := range tests {
+		if got := scope.Allows(tt.url, tt.addrs); got != tt.want {
+			t.Errorf("Allows(%q, %v) = %v, want %v", tt.url, tt.addrs, got, tt.want)
+		}
+	}
+
+	if !scope.needsAddresses() {
+		t.Error("scope with a CIDR rule does not ask for addresses")
+	}
+	if (&Scope{exclude: []scopeRule{exclude}}).needsAddresses() {
+		t.Error("scope without IP rules asks for addresses")
+	}
+}
@@ -0,0 +1,160 @@
+package src
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hostBaseline is the answer of a host to an archive name that cannot exist.
+type hostBaseline struct {
+	once      sync.Once
+	success   bool
+	payload   string
+	detection Detection
+}
+
+// scoreState keeps the per-scan evidence that scoring compares against.
+type scoreState struct {
+	mu        sync.Mutex
+	baselines map[string]*hostBaseline
+	// payloads maps host and payload key to the first path serving it.
+	payloads map[string]string
+}
+
+// scoreFinding rates a verified candidate from 0 to 100. The detector's
+// confidence is the base, the response headers, the host's soft-404
+// baseline and payloads already seen on other paths adjust it.
+func scoreFinding(archiveURL string, resp *ProbeResponse, detection Detection, config *Config, prober Prober) int {
+	score := detection.Confidence * 100
+
+	if detection.Mismatch {
+		score -= 10
+	}
+
+	if size := payloadSize(resp); size >= 0 {
+		switch {
+		case size < 100:
+			score -= 30
+		case size < 1024:
+			score -= 10
+		default:
+			score += 5
+		}
+	}
+
+	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Disposition")), "attachment") {
+		score += 10
+	}
+
+	if resp.Header.Get("Last-Modified") != "" {
+		score += 5
+	}
+
+	u, err := url.Parse(archiveURL)
+	if err == nil {
+		key := payloadKey(resp)
+
+		baseline := config.scores.baseline(u, config, prober)
+		if baseline.success {
+			if baseline.payload == key {
+				score -= 50
+			} else if baseline.detection.Type == detection.Type {
+				// The host serves archives for any name, e.g. a catch-all download
+				score -= 30
+			}
+		}
+
+		if config.scores.seenElsewhere(u, key) {
+			score -= 25
+		}
+	}
+
+	switch {
+	case score < 0:
+		return 0
+	case score > 100:
+		return 100
+	}
+	return int(score)
+}
+
+// baseline requests a random name with the candidate's extension in the
+// candidate's folder once and caches the answer.
+func (s *scoreState) baseline(u *url.URL, config *Config, prober Prober) *hostBaseline {
+	dir := path.Dir(u.Path)
+	ext := getExtension(u.Path)
+	key := u.Scheme + "://" + u.Host + dir + "|" + ext
+
+	s.mu.Lock()
+	if s.baselines == nil {
+		s.baselines = make(map[string]*hostBaseline)
+	}
+	baseline, ok := s.baselines[key]
+	if !ok {
+		baseline = &hostBaseline{}
+		s.baselines[key] = baseline
+	}
+	s.mu.Unlock()
+
+	baseline.once.Do(func() {
+		randomURL := fmt.Sprintf("%s://%s%s/%x.%s", u.Scheme, u.Host, strings.TrimSuffix(dir, "/"), rand.Int63(), ext)
+		resp, err := prober.Get(config.context(), randomURL, 2048)
+		if err != nil || !isSuccess(resp.StatusCode) {
+			return
+		}
+		baseline.success = true
+		baseline.payload = payloadKey(resp)
+		baseline.detection, _ = detect(resp.Header, resp.Body, ext)
+	})
+
+	return baseline
+}
+
+// seenElsewhere records the payload for u and reports whether another path
+// of the same host served it before.
+func (s *scoreState) seenElsewhere(u *url.URL, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.payloads == nil {
+		s.payloads = make(map[string]string)
+	}
+
+	hostKey := u.Host + "|" + key
+	first, ok := s.payloads[hostKey]
+	if !ok {
+		s.payloads[hostKey] = u.Path
+		return false
+	}
+	return first != u.Path
+}
+
+// payloadSize returns the full size of the resource, taken from
+// Content-Range for ranged answers, or -1 if unknown.
+func payloadSize(resp *ProbeResponse) int64 {
+	if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+		if i := strings.LastIndex(contentRange, "/"); i >= 0 {
+			if size, err := strconv.ParseInt(contentRange[i+1:], 10, 64); err == nil {
+				return size
+			}
+		}
+	}
+	if size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		return size
+	}
+	return -1
+}
+
+// payloadKey identifies a payload by the hash of its body prefix and its
+// full size.
+func payloadKey(resp *ProbeResponse) string {
+	sum := sha256.Sum256(bytes.TrimSpace(resp.Body))
+	return hex.EncodeToString(sum[:]) + "/" + strconv.FormatInt(payloadSize(resp), 10)
+}
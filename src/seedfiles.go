@@ -0,0 +1,179 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const maxSeedSitemaps = 3
+
+var (
+	sitemapLocRe  = regexp.MustCompile(`(?i)<loc>\s*([^<\s]+)\s*</loc>`)
+	securityURLRe = regexp.MustCompile(`(?i)^(?:contact|policy|canonical|hiring|acknowledgments|encryption):\s*(\S+)`)
+)
+
+// SeedPaths holds the directory paths and names discovered in robots.txt,
+// sitemap.xml and security.txt of a host.
+type SeedPaths struct {
+	Folders []string
+	Words   []string
+}
+
+// fetchSeedPaths requests robots.txt, the sitemaps and security.txt of a
+// host and collects at most config.SeedLimit folders and words.
+func fetchSeedPaths(baseURL string, config *Config, prober Prober) *SeedPaths {
+	seeds := &SeedPaths{}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return seeds
+	}
+
+	// robots.txt names folders and files, sitemaps and security.txt name pages
+	var paths, pages []string
+	sitemaps := []string{"sitemap.xml"}
+
+	if body := fetchSeedFile(base, "robots.txt", config, prober); body != nil {
+		robotPaths, robotSitemaps := parseRobots(body)
+		paths = append(paths, robotPaths...)
+		sitemaps = appendUnique(sitemaps, robotSitemaps...)
+	}
+
+	for i, sitemap := range sitemaps {
+		if i >= maxSeedSitemaps {
+			break
+		}
+		if body := fetchSeedFile(base, sitemap, config, prober); body != nil {
+			pages = append(pages, parseSitemap(body, base)...)
+		}
+	}
+
+	for _, location := range []string{".well-known/security.txt", "security.txt"} {
+		if body := fetchSeedFile(base, location, config, prober); body != nil {
+			pages = append(pages, parseSecurityTxt(body, base)...)
+			break
+		}
+	}
+
+	addSeedPaths(seeds, paths, false, config.SeedLimit)
+	addSeedPaths(seeds, pages, true, config.SeedLimit)
+	return seeds
+}
+
+// fetchSeedFile returns the body of a seed file or nil if it does not exist.
+// Soft-404 pages answering with HTML are ignored as well.
+func fetchSeedFile(base *url.URL, location string, config *Config, prober Prober) []byte {
+	ref, err := base.Parse(location)
+	if err != nil || ref.Hostname() != base.Hostname() {
+		return nil
+	}
+
+	status, header, body, err := fetchPage(ref.String(), config, prober)
+	if err != nil {
+		if config.Verbose {
+			config.logf(LogError, "Seed fetch failed for %s: %v", ref, err)
+		}
+		return nil
+	}
+
+	if !isSuccess(status) || strings.Contains(strings.ToLower(header.Get("Content-Type")), "text/html") {
+		return nil
+	}
+	return body
+}
+
+func parseRobots(body []byte) (paths []string, sitemaps []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "disallow", "allow":
+			paths = append(paths, value)
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		}
+	}
+	return paths, sitemaps
+}
+
+func parseSitemap(body []byte, base *url.URL) []string {
+	var paths []string
+	for _, match := range sitemapLocRe.FindAllSubmatch(body, -1) {
+		if p := sameHostPath(string(match[1]), base); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func parseSecurityTxt(body []byte, base *url.URL) []string {
+	var paths []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		match := securityURLRe.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+		if p := sameHostPath(match[1], base); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func sameHostPath(raw string, base *url.URL) string {
+	ref, err := base.Parse(raw)
+	if err != nil || ref.Hostname() != base.Hostname() {
+		return ""
+	}
+	return ref.Path
+}
+
+// addSeedPaths turns URL paths into backup folders (the directory part) and
+// base words (its single segments). Wildcards and file names are skipped.
+// The last segment counts as a file if it contains a dot, or always for
+// pages.
+func addSeedPaths(seeds *SeedPaths, paths []string, pages bool, limit int) {
+	for _, p := range paths {
+		// Cut robots.txt patterns; a wildcard also cuts the partial segment
+		// before it
+		cutFile := pages
+		if i := strings.IndexAny(p, "*$?#"); i >= 0 {
+			cutFile = cutFile || p[i] == '*'
+			p = p[:i]
+		}
+		if i := strings.LastIndex(p, "/"); i >= 0 && (cutFile || strings.Contains(p[i+1:], ".")) {
+			p = p[:i]
+		}
+
+		var segments []string
+		for _, segment := range strings.Split(strings.Trim(p, "/"), "/") {
+			if segment == "" || len(segment) > maxHarvestedFolder || isIrrelevantPart(segment) || numberRegex.MatchString(segment) {
+				segments = nil
+				break
+			}
+			segments = append(segments, segment)
+		}
+		if len(segments) == 0 {
+			continue
+		}
+
+		if len(seeds.Folders) < limit {
+			seeds.Folders = appendUnique(seeds.Folders, strings.Join(segments, "/"))
+		}
+		for _, segment := range segments {
+			if len(seeds.Words) < limit {
+				seeds.Words = appendUnique(seeds.Words, segment)
+			}
+		}
+	}
+}
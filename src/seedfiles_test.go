@@ -0,0 +1,34 @@
+package src
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddSeedPaths(t *testing.T) {
+	tests := []struct {
+		path  string
+		pages bool
+		want  []string
+	}{
+		{path: "/backup", want: []string{"backup"}},
+		{path: "/backup/", want: []string{"backup"}},
+		{path: "/admin/*.php", want: []string{"admin"}},
+		{path: "/admin/back*", want: []string{"admin"}},
+		{path: "/a/b.html", want: []string{"a"}},
+		{path: "/old/site$", want: []string{"old/site"}},
+		{path: "/search?q=", want: []string{"search"}},
+		{path: "/index.php", want: nil},
+		{path: "/", want: nil},
+		{path: "/blog/first-post", pages: true, want: []string{"blog"}},
+		{path: "/blog/", pages: true, want: []string{"blog"}},
+	}
+
+	for _, tt := range tests {
+		seeds := &SeedPaths{}
+		addSeedPaths(seeds, []string{tt.path}, tt.pages, 10)
+		if !reflect.DeepEqual(seeds.Folders, tt.want) {
+			t.Errorf("addSeedPaths(%q, pages=%v) = %v, want %v", tt.path, tt.pages, seeds.Folders, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// priorWeight is the number of pseudo requests the built-in rates count
+// for, so a handful of own results does not overturn them at once.
+const priorWeight = 100
+
+// Built-in hit rates of the parts of a candidate. They are guesses that
+// only set the initial order, not measured rates; the stats file replaces
+// them with real ones over time. Parts without an entry use the default
+// rate.
+var (
+	builtinWordRates = map[string]float64{
+		"backup": 0.08, "www": 0.05, "site": 0.04, "website": 0.03, "html": 0.03,
+		"db": 0.03, "database": 0.03, "dump": 0.03, "sql": 0.03, "mysql": 0.02,
+		"app": 0.02, "web": 0.02, "public": 0.02, "data": 0.02, "old": 0.02,
+		"backup1": 0.01, "backup_full": 0.01, "files": 0.01, "src": 0.01,
+	}
+	builtinExtensionRates = map[string]float64{
+		"zip": 0.08, "tar.gz": 0.06, "sql.gz": 0.04, "tgz": 0.03, "rar": 0.03,
+		"7z": 0.02, "tar": 0.02, "gz": 0.02, "bz2": 0.01, "jpa": 0.01,
+		"xls": 0.005, "xlsx": 0.005, "exe": 0.002, "dll": 0.002,
+	}
+	builtinFolderRates = map[string]float64{
+		"": 0.06, "backups": 0.04, "backup": 0.04, "_backups": 0.02, "old": 0.02,
+	}
+)
+
+const defaultPartRate = 0.01
+
+// PatternStats counts how often candidates with a word, extension or
+// folder were requested and how often they were archives.
+type PatternStats struct {
+	Tried int64 `json:"tried"`
+	Hits  int64 `json:"hits"`
+}
+
+// candidateModel estimates how likely a candidate is an archive from the
+// hit rates of its word, extension and folder: the built-in rates updated
+// by the results of past scans in the stats file.
+type candidateModel struct {
+	mu         sync.Mutex
+	Words      map[string]*PatternStats `json:"words"`
+	Extensions map[string]*PatternStats `json:"extensions"`
+	Folders    map[string]*PatternStats `json:"folders"`
+
+	// Only words and folders of the wordlists are learned, names derived
+	// from a single host would just bloat the file.
+	vocabulary map[string]bool
+}
+
+// loadModel reads the stats file; a missing file starts an empty one.
+func loadModel(statsFile string) (*candidateModel, error) {
+	model := &candidateModel{}
+	data, err := os.ReadFile(statsFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, model); err != nil {
+			return nil, err
+		}
+	}
+	return model, nil
+}
+
+// save writes the model back, replacing the file only once it is complete.
+func (m *candidateModel) save(statsFile string) error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := statsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statsFile)
+}
+
+// candidateParts splits a candidate URL into folder, word and extension.
+func candidateParts(candidate string) (folder, word, ext string) {
+	u, err := url.Parse(candidate)
+	if err != nil {
+		return "", "", ""
+	}
+	ext = getExtension(u.Path)
+	folder = strings.Trim(path.Dir(u.Path), "/")
+	word = strings.TrimSuffix(path.Base(u.Path), "."+ext)
+	return strings.ToLower(folder), strings.ToLower(word), ext
+}
+
+// likelihood returns the estimated hit rate of a candidate.
+func (m *candidateModel) likelihood(candidate string) float64 {
+	folder, word, ext := candidateParts(candidate)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return partRate(m.Words, builtinWordRates, word) *
+		partRate(m.Extensions, builtinExtensionRates, ext) *
+		partRate(m.Folders, builtinFolderRates, folder)
+}
+
+func partRate(stats map[string]*PatternStats, builtin map[string]float64, key string) float64 {
+	prior, ok := builtin[key]
+	if !ok {
+		prior = defaultPartRate
+	}
+	var tried, hits int64
+	if s := stats[key]; s != nil {
+		tried, hits = s.Tried, s.Hits
+	}
+	return (float64(hits) + prior*priorWeight) / (float64(tried) + priorWeight)
+}
+
+// learn adds words and folders to the vocabulary of the model.
+func (m *candidateModel) learn(words ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.vocabulary == nil {
+		m.vocabulary = map[string]bool{"": true}
+	}
+	for _, word := range words {
+		m.vocabulary[strings.ToLower(word)] = true
+	}
+}
+
+// record counts the outcome of a checked candidate.
+func (m *candidateModel) record(candidate string, found bool) {
+	folder, word, ext := candidateParts(candidate)
+	if ext == "" {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Extensions = countPart(m.Extensions, ext, found)
+	if m.vocabulary[word] {
+		m.Words = countPart(m.Words, word, found)
+	}
+	if m.vocabulary[folder] {
+		m.Folders = countPart(m.Folders, folder, found)
+	}
+}
+
+func countPart(stats map[string]*PatternStats, key string, found bool) map[string]*PatternStats {
+	if stats == nil {
+		stats = make(map[string]*PatternStats)
+	}
+	s := stats[key]
+	if s == nil {
+		s = &PatternStats{}
+		stats[key] = s
+	}
+	s.Tried++
+	if found {
+		s.Hits++
+	}
+	return stats
+}
@@ -0,0 +1,82 @@
+package src
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCandidateParts(t *testing.T) {
+	tests := []struct {
+		candidate         string
+		folder, word, ext string
+	}{
+		{"https://example.com/backup.zip", "", "backup", "zip"},
+		{"https://example.com/Old/Site.tar.gz", "old", "site", "tar.gz"},
+		{"https://example.com/a/b/db.sql.gz", "a/b", "db", "sql.gz"},
+		{"https://example.com/readme", "", "readme", ""},
+	}
+	for _, tt := range tests {
+		folder, word, ext := candidateParts(tt.candidate)
+		if folder != tt.folder || word != tt.word || ext != tt.ext {
+			t.Errorf("candidateParts(%q) = %q, %q, %q, want %q, %q, %q", tt.candidate, folder, word, ext, tt.folder, tt.word, tt.ext)
+		}
+	}
+}
+
+func TestPartRate(t *testing.T) {
+	builtin := map[string]float64{"backup": 0.1}
+	stats := map[string]*PatternStats{
+		"backup": {Tried: 100, Hits: 30},
+		"site":   {Tried: 900, Hits: 0},
+	}
+	tests := []struct {
+		key  string
+		want float64
+	}{
+		{"unknown", defaultPartRate},
+		{"backup", (30 + 0.1*priorWeight) / (100 + priorWeight)},
+		{"site", defaultPartRate * priorWeight / (900 + priorWeight)},
+	}
+	for _, tt := range tests {
+		if got := partRate(stats, builtin, tt.key); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("partRate(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+	if got := partRate(nil, builtin, "backup"); got != 0.1 {
+		t.Errorf("partRate without stats = %v, want the built-in 0.1", got)
+	}
+}
+
+func TestModelSaveLoad(t *testing.T) {
+	statsFile := filepath.Join(t.TempDir(), "stats.json")
+	model, err := loadModel(statsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.learn("backup", "backups")
+	model.record("https://example.com/backups/backup.zip", true)
+	model.record("https://example.com/backups/example.zip", false)
+	model.record("https://example.com/readme", false)
+	if err := model.save(statsFile); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadModel(statsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]*PatternStats{
+		"words":      {"backup": {Tried: 1, Hits: 1}},
+		"extensions": {"zip": {Tried: 2, Hits: 1}},
+		"folders":    {"backups": {Tried: 2, Hits: 1}},
+	}
+	got := map[string]map[string]*PatternStats{"words": loaded.Words, "extensions": loaded.Extensions, "folders": loaded.Folders}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if loaded.likelihood("https://example.com/backups/backup.zip") != model.likelihood("https://example.com/backups/backup.zip") {
+		t.Error("likelihood changed by the round-trip")
+	}
+}
@@ -0,0 +1,227 @@
+package src
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// vhostGroup is a set of hosts on one address serving the same site. The
+// representative is scanned in full; the other members only for the paths
+// it did not request, i.e. the candidates derived from their own names.
+type vhostGroup struct {
+	representative string
+	// done is closed once the representative is scanned
+	done chan struct{}
+
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+// record stores the candidates the representative requested on baseURL.
+func (g *vhostGroup) record(baseURL string, checked map[string]bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for candidate := range checked {
+		if key, ok := groupPath(baseURL, candidate); ok {
+			g.paths[key] = true
+		}
+	}
+}
+
+// unseen passes on the candidates of ch the representative did not request.
+func (g *vhostGroup) unseen(baseURL string, ch <-chan string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for candidate := range ch {
+			key, ok := groupPath(baseURL, candidate)
+			g.mu.Lock()
+			seen := ok && g.paths[key]
+			g.mu.Unlock()
+			if !seen {
+				out <- candidate
+			}
+		}
+	}()
+	return out
+}
+
+// groupPath returns the scheme and path of candidate below baseURL.
+func groupPath(baseURL string, candidate string) (string, bool) {
+	if !strings.HasPrefix(candidate, baseURL) {
+		return "", false
+	}
+	scheme := baseURL[:strings.Index(baseURL, ":")]
+	return scheme + "|" + strings.TrimPrefix(candidate, baseURL), true
+}
+
+// groupVirtualHosts fingerprints hosts that share an address and groups
+// them by address and fingerprint. The first host of every group is its
+// representative; the other members are moved behind all representatives,
+// as they wait for theirs to be done.
+func groupVirtualHosts(hosts []string, config *Config, prober Prober) []string {
+	byIP := make(map[string][]int)
+	for i, host := range hosts {
+		if addrs := config.dns.lookup(hostName(host)); len(addrs) > 0 {
+			byIP[addrs[0]] = append(byIP[addrs[0]], i)
+		}
+	}
+
+	var shared []int
+	for _, indexes := range byIP {
+		if len(indexes) > 1 {
+			shared = append(shared, indexes...)
+		}
+	}
+
+	fingerprints := make([]string, len(hosts))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < config.DNSConcurrency && i < len(shared); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				fingerprints[index] = hostFingerprint(hosts[index], config, prober)
+			}
+		}()
+	}
+	for _, index := range shared {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	groups := make(map[string]*vhostGroup)
+	config.vhosts = make(map[string]*vhostGroup)
+	var result, members []string
+	for i, host := range hosts {
+		if fingerprints[i] == "" {
+			result = append(result, host)
+			continue
+		}
+		key := config.dns.lookup(hostName(host))[0] + "|" + fingerprints[i]
+		if group, ok := groups[key]; ok {
+			// Only groups with members need the bookkeeping
+			config.vhosts[group.representative] = group
+			config.vhosts[host] = group
+			members = append(members, host)
+			atomic.AddInt64(&config.GroupedHosts, 1)
+			if config.Verbose {
+				config.logf(LogVerbose, "host=%s grouped with %s", host, group.representative)
+			}
+			continue
+		}
+		group := &vhostGroup{representative: host, done: make(chan struct{}), paths: make(map[string]bool)}
+		groups[key] = group
+		result = append(result, host)
+	}
+
+	config.logf(LogInfo, "Virtual host groups: %d hosts on shared addresses, %d scanned only for their own candidates",
+		len(shared), len(members))
+	return append(result, members...)
+}
+
+// hostFingerprint combines the answers to the start page and to an archive
+// name that cannot exist. Hosts with the same fingerprint on the same
+// address serve the same content, e.g. the default site of a shared hoster.
+// An empty fingerprint means the host did not answer.
+func hostFingerprint(host string, config *Config, prober Prober) string {
+	baseURL := normalizeHost(fingerprintTarget(host, config))
+	if baseURL == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(config.context(), preflightTimeout)
+	defer cancel()
+
+	page, err := prober.Get(ctx, baseURL, maxHtmlRead)
+	if err != nil {
+		return ""
+	}
+	random, err := prober.Get(ctx, fmt.Sprintf("%s%x.zip", baseURL, rand.Int63()), 2048)
+	if err != nil {
+		return ""
+	}
+
+	// Other ports and schemes on the same address are different servers
+	sum := sha256.New()
+	if u, err := url.Parse(baseURL); err == nil {
+		fmt.Fprintf(sum, "%s|%s|", u.Scheme, u.Port())
+	}
+	for _, resp := range []*ProbeResponse{page, random} {
+		fmt.Fprintf(sum, "%d|%s|", resp.StatusCode, locationPath(resp.Header.Get("Location")))
+		sum.Write(withoutHostName(resp.Body, hostName(host)))
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// fingerprintTarget picks the scheme the host would most likely be scanned
+// with, without a preflight of its own.
+func fingerprintTarget(host string, config *Config) string {
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return host
+	}
+	if config.SchemeMode == SchemeHTTPOnly {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+func locationPath(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	return u.Path
+}
+
+// withoutHostName blanks out the host name, which often shows up in links
+// of otherwise identical pages.
+func withoutHostName(body []byte, name string) []byte {
+	if name == "" {
+		return body
+	}
+	return []byte(strings.ReplaceAll(strings.ToLower(string(body)), strings.ToLower(name), ""))
+}
+
+// ipLimiters holds a limiter per server address.
+type ipLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*limiter
+}
+
+// forURL returns the limiter of the address targetURL connects to, or nil
+// without config.PerIPConcurrency. Hosts outside the DNS cache are limited
+// by their name.
+func (l *ipLimiters) forURL(targetURL string, config *Config) *limiter {
+	if config.PerIPConcurrency <= 0 {
+		return nil
+	}
+	key := targetURL
+	if u, err := url.Parse(targetURL); err == nil {
+		key = u.Hostname()
+		if addrs := config.dns.lookup(key); len(addrs) > 0 {
+			key = addrs[0]
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limiters == nil {
+		l.limiters = make(map[string]*limiter)
+	}
+	lim, ok := l.limiters[key]
+	if !ok {
+		lim = newLimiter(config.PerIPConcurrency)
+		l.limiters[key] = lim
+	}
+	return lim
+}
@@ -0,0 +1,31 @@
+package src
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVHostGroupSkipsPathsOfRepresentative(t *testing.T) {
+	group := &vhostGroup{representative: "shop.example.com", done: make(chan struct{}), paths: make(map[string]bool)}
+	group.record("https://shop.example.com/", map[string]bool{
+		"https://shop.example.com/backup.zip":       true,
+		"https://shop.example.com/shop.zip":         true,
+		"https://shop.example.com/backups/site.zip": true,
+	})
+
+	candidates := []string{
+		"https://blog.example.com/backup.zip",
+		"https://blog.example.com/blog.zip",
+		"https://blog.example.com/backups/site.zip",
+		"http://blog.example.com/backup.zip",
+	}
+	var got []string
+	for candidate := range group.unseen("https://blog.example.com/", sliceChan(candidates)) {
+		got = append(got, candidate)
+	}
+
+	want := []string{"https://blog.example.com/blog.zip", "http://blog.example.com/backup.zip"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}